@@ -17,10 +17,12 @@
 package fortuna
 
 import (
+	"context"
 	"crypto/aes"
+	"errors"
 	"hash"
+	"io"
 	"os"
-	"strconv"
 	"sync"
 	"time"
 
@@ -32,6 +34,11 @@ const (
 	minPoolSize            = 32
 	minReseedInterval      = 100 * time.Millisecond
 	seedFileUpdateInterval = 10 * time.Minute
+
+	// defaultStarvationThreshold is the default time without new
+	// entropy after which the Accumulator is considered to be starved
+	// of entropy, see .EntropyStarved().
+	defaultStarvationThreshold = 10 * time.Minute
 )
 
 // Accumulator holds the state of one instance of the Fortuna random
@@ -43,22 +50,46 @@ const (
 // It is safe to access an Accumulator object concurrently from
 // different goroutines.
 type Accumulator struct {
-	seedFile     *os.File
-	stopAutoSave chan<- bool
-
-	genMutex sync.Mutex
-	gen      *Generator
-
-	poolMutex    sync.Mutex
-	reseedCount  int
-	nextReseed   time.Time
-	pool         [numPools]hash.Hash
-	poolZeroSize int
+	seedFile         *os.File
+	stopAutoSave     chan<- bool
+	seedErrorHandler func(error)
+
+	// clock returns the current time.  This can be replaced for
+	// testing.
+	clock func() time.Time
+
+	genMutex       sync.Mutex
+	gen            *Generator
+	bytesGenerated uint64
+	maxReadSize    int
+	truncateReads  bool
+	tracer         Tracer
+
+	poolMutex   sync.Mutex
+	reseedCount int
+	lastReseed  time.Time
+	lastPools   []int
+	policy      ReseedPolicy
+	pool        [numPools]hash.Hash
+	poolSize    [numPools]int
+	poolsClosed bool
+
+	// poolZeroSources records, for every entropy source, the number
+	// of bytes submitted to pool 0 since the last reseed.
+	poolZeroSources   [256]int
+	maxSourceFraction float64
+
+	lastEvent           time.Time
+	lastDraw            time.Time
+	starvationThreshold time.Duration
 
 	sourceMutex sync.Mutex
 	nextSource  uint8
 	stopSources chan bool
 	sources     sync.WaitGroup
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // NewRNG allocates a new instance of the Fortuna random number
@@ -71,6 +102,13 @@ type Accumulator struct {
 // not be shared between concurrently running instances of the random
 // number generator.
 //
+// The seed file is read and immediately overwritten with fresh
+// random data when the generator is created, and it is updated again
+// periodically and when the generator is closed.  Thus, every restart
+// of the program starts from a different seed, and output from one
+// run is never repeated in a later run, even if the seed file is the
+// only source of entropy.
+//
 // In case the seed file does not exist, a new seed file is created.
 // If a corrupted seed file is found, ErrCorruptedSeed is returned.
 // If a seed file with insecure file permissions is found,
@@ -87,6 +125,15 @@ var (
 	// NewAccumulatorAES is an alias for NewRNG, provided for backward
 	// compatibility.  It should not be used in new code.
 	NewAccumulatorAES = NewRNG
+
+	// ErrReadTooLarge is returned by Accumulator.Read() if the
+	// number of bytes requested exceeds the limit set using
+	// SetMaxReadSize().
+	ErrReadTooLarge = errors.New("read exceeds maximum size")
+
+	// ErrNotAtomic is returned by Accumulator.ReadAtomic() if the
+	// requested data cannot be generated using a single key.
+	ErrNotAtomic = errors.New("read too large to be atomic")
 )
 
 // NewAccumulator allocates a new instance of the Fortuna random
@@ -96,8 +143,95 @@ var (
 // NewRNG(seedFileName).  See the documentation for NewRNG() for more
 // information.
 func NewAccumulator(newCipher NewCipher, seedFileName string) (*Accumulator, error) {
+	return newAccumulator(newCipher, seedFileName, nil)
+}
+
+// NewAccumulatorWithReaders allocates a new instance of the Fortuna
+// random number generator, using additional entropy sources for the
+// initial seed.  From each of the readers in 'sources', 'numBytes'
+// bytes are read in parallel, and the data obtained is used to
+// reseed the generator before the seed file is processed.  Sources
+// which fail, which return fewer than 'numBytes' bytes, or which do
+// not finish within the given timeout are ignored.  If none of the
+// sources succeeds, ErrNoEntropy is returned.
+//
+// The additional sources supplement, but do not replace, the initial
+// seed described in the documentation for NewGenerator().  See the
+// documentation for NewRNG() for information about the seed file.
+func NewAccumulatorWithReaders(newCipher NewCipher, seedFileName string,
+	sources []io.Reader, numBytes int, timeout time.Duration) (*Accumulator, error) {
+	seed, err := readSeedSources(sources, numBytes, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(seed)
+	return newAccumulator(newCipher, seedFileName, seed)
+}
+
+// NewAccumulatorWithSources allocates a new instance of the Fortuna
+// random number generator, using the given seeding strategies to
+// obtain the initial seed.  The strategies are tried in the given
+// order until at least 32 bytes of seed data have been obtained; the
+// data from all successful strategies is combined.  Strategies which
+// fail are skipped.  If not enough data can be obtained, ErrNoEntropy
+// is returned.  Strategies are provided by ReaderSeedStrategy(),
+// FileSeedStrategy() and JitterSeedStrategy(); other strategies can
+// be implemented as functions of type SeedStrategy.
+//
+// The seed obtained this way supplements, but does not replace, the
+// initial seed described in the documentation for NewGenerator().
+// See the documentation for NewAccumulator() and NewRNG() for the
+// meaning of the arguments newCipher and seedFileName.
+func NewAccumulatorWithSources(newCipher NewCipher, seedFileName string,
+	strategies ...SeedStrategy) (*Accumulator, error) {
+	seed, err := runSeedStrategies(strategies)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(seed)
+	return newAccumulator(newCipher, seedFileName, seed)
+}
+
+// NewAccumulatorContext allocates a new instance of the Fortuna random
+// number generator, which is closed automatically when ctx is
+// cancelled.  Closing stops all entropy sources and the periodic
+// updates of the seed file, and writes the seed file a final time,
+// exactly as the .Close() method does.  This allows to tie the
+// lifetime of the Accumulator to the root context of a service.
+// Errors from closing the Accumulator can be obtained by calling
+// .Close() after the context has been cancelled.
+//
+// See the documentation for NewAccumulator() and NewRNG() for the
+// meaning of the remaining arguments.
+func NewAccumulatorContext(ctx context.Context, newCipher NewCipher,
+	seedFileName string) (*Accumulator, error) {
+	acc, err := NewAccumulator(newCipher, seedFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			acc.Close()
+		case <-acc.stopSources:
+			// closed by the caller
+		}
+	}()
+
+	return acc, nil
+}
+
+func newAccumulator(newCipher NewCipher, seedFileName string, seed []byte) (*Accumulator, error) {
 	acc := &Accumulator{
-		gen: NewGenerator(newCipher),
+		gen:                 NewGenerator(newCipher),
+		clock:               time.Now,
+		policy:              specPolicy{},
+		starvationThreshold: defaultStarvationThreshold,
+	}
+	acc.lastEvent = acc.clock()
+	if seed != nil {
+		acc.gen.Reseed(seed)
 	}
 	for i := 0; i < len(acc.pool); i++ {
 		acc.pool[i] = sha256d.New()
@@ -136,7 +270,7 @@ func NewAccumulator(newCipher NewCipher, seedFileName string) (*Accumulator, err
 				case <-quit:
 					return
 				case <-ticker:
-					acc.writeSeedFile()
+					acc.autoSaveSeed()
 				}
 			}
 		}()
@@ -157,7 +291,10 @@ func (acc *Accumulator) tearDownPools() {
 		data = acc.pool[i].Sum(data)
 		acc.pool[i] = nil
 	}
-	acc.poolZeroSize = 0 // prevent accidential last-minute reseeding
+	// prevent accidential last-minute reseeding
+	acc.poolsClosed = true
+	acc.poolSize[0] = 0
+	acc.poolZeroSources = [256]int{}
 	acc.poolMutex.Unlock()
 
 	acc.genMutex.Lock()
@@ -166,66 +303,249 @@ func (acc *Accumulator) tearDownPools() {
 }
 
 func (acc *Accumulator) tryReseeding() []byte {
-	now := time.Now()
+	now := acc.clock()
 
 	acc.poolMutex.Lock()
 	defer acc.poolMutex.Unlock()
 
-	if acc.poolZeroSize >= minPoolSize && now.After(acc.nextReseed) {
-		acc.nextReseed = now.Add(minReseedInterval)
-		acc.poolZeroSize = 0
-		acc.reseedCount++
-
-		seed := make([]byte, 0, numPools*sha256d.Size)
-		pools := []string{}
-		for i := uint(0); i < numPools; i++ {
-			x := 1 << i
-			if acc.reseedCount%x != 0 {
-				break
-			}
-			seed = acc.pool[i].Sum(seed)
-			acc.pool[i].Reset()
-			pools = append(pools, strconv.Itoa(int(i)))
+	acc.lastDraw = now
+	if acc.poolsClosed {
+		// The pools have been freed by .tearDownPools(), so the
+		// reseed policy must not be consulted any more.
+		return nil
+	}
+
+	state := PoolState{
+		Sizes:       acc.poolSize,
+		ReseedSize:  acc.poolZeroSize(),
+		ReseedCount: acc.reseedCount,
+	}
+	reseed, pools := acc.policy.ShouldReseed(state, now, acc.lastReseed)
+	if !reseed {
+		return nil
+	}
+
+	acc.lastReseed = now
+	acc.lastPools = append(acc.lastPools[:0], pools...)
+	acc.reseedCount++
+
+	seed := make([]byte, 0, numPools*sha256d.Size)
+	for _, i := range pools {
+		if i < 0 || i >= numPools {
+			panic("invalid pool index from reseed policy")
+		}
+		seed = acc.pool[i].Sum(seed)
+		acc.pool[i].Reset()
+		acc.poolSize[i] = 0
+		if i == 0 {
+			acc.poolZeroSources = [256]int{}
 		}
-		return seed
 	}
-	return nil
+	return seed
 }
 
 // RandomData returns a slice of n random bytes.  The result can be
 // used as a replacement for a sequence of uniformly distributed and
 // independent bytes, and will be difficult to guess for an attacker.
 func (acc *Accumulator) RandomData(n uint) []byte {
+	return acc.RandomDataContext(context.Background(), n)
+}
+
+// RandomDataContext is like .RandomData(), but spans reported to the
+// tracer set by .SetTracer() are created as children of the span in
+// ctx, so that the generator's activity shows up in the trace of the
+// request which caused it.
+func (acc *Accumulator) RandomDataContext(ctx context.Context, n uint) []byte {
 	seed := acc.tryReseeding()
 	acc.genMutex.Lock()
 	defer acc.genMutex.Unlock()
-	if seed != nil {
-		acc.gen.Reseed(seed)
-	}
-	return acc.gen.PseudoRandomData(n)
+	return acc.generate(ctx, n, seed)
 }
 
 func (acc *Accumulator) randomDataUnlocked(n uint) []byte {
 	seed := acc.tryReseeding()
+	return acc.generate(context.Background(), n, seed)
+}
+
+// generate reseeds the generator if seed is non-nil, and then returns
+// n bytes of output.  The caller must hold acc.genMutex.
+func (acc *Accumulator) generate(ctx context.Context, n uint, seed []byte) []byte {
 	if seed != nil {
+		span := acc.startSpan(ctx, "fortuna.reseed")
 		acc.gen.Reseed(seed)
+		if span != nil {
+			span.SetAttribute("fortuna.seed_bytes", int64(len(seed)))
+			span.end()
+		}
+	}
+
+	var span *timedSpan
+	if n >= traceMinBytes {
+		span = acc.startSpan(ctx, "fortuna.generate")
+	}
+	acc.bytesGenerated += uint64(n)
+	res := acc.gen.PseudoRandomData(n)
+	if span != nil {
+		numBlocks := acc.gen.numBlocks(n)
+		span.SetAttribute("fortuna.bytes", int64(n))
+		span.SetAttribute("fortuna.rekeys",
+			int64((numBlocks+maxBlocks-1)/maxBlocks))
+		span.end()
+	}
+	return res
+}
+
+// LastReseedPools returns the indices of the entropy pools which were
+// used for the most recent reseed of the generator, in increasing
+// order.  With the default reseed policy, pool 0 is used for every
+// reseed, pool 1 for every second reseed, pool 2 for every fourth
+// reseed, and so on.  If the generator has not been reseeded yet,
+// nil is returned.
+func (acc *Accumulator) LastReseedPools() []int {
+	acc.poolMutex.Lock()
+	defer acc.poolMutex.Unlock()
+	if acc.lastPools == nil {
+		return nil
+	}
+	return append([]int(nil), acc.lastPools...)
+}
+
+// AccumulatorStats describes the state of an Accumulator at one point
+// in time.  Objects of this type are returned by the .Stats() method.
+type AccumulatorStats struct {
+	// ReseedCount gives the number of times the generator has been
+	// reseeded from the entropy pools.
+	ReseedCount int
+
+	// LastReseed gives the time of the most recent reseed.  If the
+	// generator has not been reseeded yet, this is the zero time.
+	LastReseed time.Time
+
+	// PoolSizes gives, for each entropy pool, the number of bytes
+	// submitted to the pool since the pool was last used for
+	// reseeding.
+	PoolSizes [numPools]int
+
+	// BytesGenerated gives the total number of random bytes
+	// extracted from the Accumulator, including the data written to
+	// the seed file.  Like Generator.BytesReturned(), this counts
+	// the bytes handed out, not the cipher blocks generated.
+	BytesGenerated uint64
+}
+
+// Stats returns a snapshot of the internal statistics of the
+// Accumulator.  All fields of the result are captured at the same
+// time, so that the values are consistent with each other.
+func (acc *Accumulator) Stats() AccumulatorStats {
+	acc.genMutex.Lock()
+	defer acc.genMutex.Unlock()
+	acc.poolMutex.Lock()
+	defer acc.poolMutex.Unlock()
+
+	return AccumulatorStats{
+		ReseedCount:    acc.reseedCount,
+		LastReseed:     acc.lastReseed,
+		PoolSizes:      acc.poolSize,
+		BytesGenerated: acc.bytesGenerated,
 	}
-	return acc.gen.PseudoRandomData(n)
 }
 
 // Read allows to extract randomness from the Accumulator using the
 // io.Reader interface.  Read fills the byte slice p with random
-// bytes.  The method always reads len(p) bytes and never returns an
-// error.
+// bytes.  Unless a maximum read size has been set using the
+// .SetMaxReadSize() method, Read always reads len(p) bytes and never
+// returns an error.
 func (acc *Accumulator) Read(p []byte) (n int, err error) {
-	copy(p, acc.RandomData(uint(len(p))))
+	return acc.ReadContext(context.Background(), p)
+}
+
+// ReadContext is like .Read(), but spans reported to the tracer set by
+// .SetTracer() are created as children of the span in ctx.
+func (acc *Accumulator) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	acc.genMutex.Lock()
+	maxSize, truncate := acc.maxReadSize, acc.truncateReads
+	acc.genMutex.Unlock()
+
+	n = len(p)
+	if maxSize > 0 && n > maxSize {
+		if !truncate {
+			return 0, ErrReadTooLarge
+		}
+		n = maxSize
+	}
+	copy(p, acc.RandomDataContext(ctx, uint(n)))
+	return n, nil
+}
+
+// ReadAtomic fills p with random bytes which are all generated using
+// a single key of the underlying generator.  No reseed from the
+// entropy pools happens while the data is generated.  This is useful
+// for protocols which require all data from one read to come from one
+// key epoch.
+//
+// At most 2^16 cipher blocks, i.e. 1 MiB when AES is used, can be
+// generated using one key.  Requests up to this size are served
+// exactly as by .Read(), which also uses a single key for them; the
+// only difference is that larger requests, which .Read() would serve
+// using several keys, fail with ErrNotAtomic and leave p unchanged.
+// The limit set by .SetMaxReadSize() does not apply to ReadAtomic.
+func (acc *Accumulator) ReadAtomic(p []byte) (n int, err error) {
+	acc.genMutex.Lock()
+	limit := maxBlocks * len(acc.gen.counter)
+	acc.genMutex.Unlock()
+	if len(p) > limit {
+		return 0, ErrNotAtomic
+	}
+
+	// RandomData holds genMutex for the whole request, and
+	// PseudoRandomData only rekeys after maxBlocks blocks.
+	data := acc.RandomData(uint(len(p)))
+	copy(p, data)
+	wipe(data)
 	return len(p), nil
 }
 
+// SetMaxReadSize limits the number of bytes which can be obtained
+// from a single call to the .Read() method to n.  This can be used to
+// protect services which hand out random data over the network
+// against clients requesting excessive amounts of data.  Larger reads
+// fail with ErrReadTooLarge, or are truncated to n bytes if
+// truncation has been enabled using .SetTruncateReads().  If n is
+// zero or negative, the size of reads is not limited.  This is the
+// default.
+//
+// The limit applies to individual calls of .Read() only.  Callers
+// like io.Copy, which read repeatedly, are not affected by
+// truncation.
+func (acc *Accumulator) SetMaxReadSize(n int) {
+	acc.genMutex.Lock()
+	defer acc.genMutex.Unlock()
+	acc.maxReadSize = n
+}
+
+// SetTruncateReads determines how the .Read() method handles
+// requests which exceed the limit set by .SetMaxReadSize().  If
+// truncate is true, only the permitted number of bytes is returned;
+// otherwise ErrReadTooLarge is returned.
+func (acc *Accumulator) SetTruncateReads(truncate bool) {
+	acc.genMutex.Lock()
+	defer acc.genMutex.Unlock()
+	acc.truncateReads = truncate
+}
+
 // Close must be called before the program exits to ensure that the
 // seed file is correctly updated.  After Close has been called the
-// Accumulator must not be used any more.
+// Accumulator must not be used any more.  Calling Close more than once
+// is harmless; later calls wait for the first call to complete and
+// return the same error.
 func (acc *Accumulator) Close() error {
+	acc.closeOnce.Do(func() {
+		acc.closeErr = acc.close()
+	})
+	return acc.closeErr
+}
+
+func (acc *Accumulator) close() error {
 	close(acc.stopSources)
 	acc.sources.Wait()
 
@@ -239,11 +559,11 @@ func (acc *Accumulator) Close() error {
 		acc.seedFile = nil
 	}
 
-	// Reset the underlying PRNG to ensure that (1) the Accumulator
+	// Close the underlying PRNG to ensure that (1) the Accumulator
 	// cannot be used any more after Close() has been called and (2)
 	// information about the key is not retained in memory
 	// indefinitely.
-	acc.gen.reset()
+	acc.gen.Close()
 
 	return err
 }
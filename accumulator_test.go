@@ -18,6 +18,8 @@ package fortuna
 
 import (
 	"bytes"
+	"context"
+	"crypto/aes"
 	"crypto/rand"
 	"io"
 	"io/ioutil"
@@ -88,6 +90,43 @@ func TestAccumulator(t *testing.T) {
 	}
 }
 
+func TestStats(t *testing.T) {
+	acc, _ := NewRNG("")
+
+	stats := acc.Stats()
+	if stats.ReseedCount != 0 || !stats.LastReseed.IsZero() ||
+		stats.BytesGenerated != 0 {
+		t.Errorf("wrong initial stats: %v", stats)
+	}
+
+	for i := uint(0); i < 3; i++ {
+		acc.addRandomEvent(0, i, make([]byte, 32))
+	}
+	acc.addRandomEvent(0, 0, make([]byte, 32))
+	stats = acc.Stats()
+	if stats.PoolSizes[0] != 2*34 || stats.PoolSizes[1] != 34 ||
+		stats.PoolSizes[2] != 34 || stats.PoolSizes[3] != 0 {
+		t.Errorf("wrong pool sizes: %v", stats.PoolSizes)
+	}
+
+	before := time.Now()
+	acc.RandomData(100)
+	acc.RandomData(23)
+	stats = acc.Stats()
+	if stats.ReseedCount != 1 {
+		t.Errorf("wrong reseed count %d", stats.ReseedCount)
+	}
+	if stats.LastReseed.Before(before) {
+		t.Error("wrong reseed time")
+	}
+	if stats.PoolSizes[0] != 0 || stats.PoolSizes[1] != 34 {
+		t.Errorf("wrong pool sizes after reseed: %v", stats.PoolSizes)
+	}
+	if stats.BytesGenerated != 123 {
+		t.Errorf("wrong number of generated bytes %d", stats.BytesGenerated)
+	}
+}
+
 func TestClose(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -118,6 +157,57 @@ func TestClose(t *testing.T) {
 	}
 }
 
+func TestCloseTwice(t *testing.T) {
+	acc, _ := NewRNG("")
+	err1 := acc.Close()
+	err2 := acc.Close()
+	if err1 != nil || err2 != nil {
+		t.Error(err1, err2)
+	}
+}
+
+func TestContext(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	seedFileName := filepath.Join(tempDir, "seed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	acc, err := NewAccumulatorContext(ctx, aes.NewCipher, seedFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := acc.NewEntropyTimeStampSink()
+	sink <- time.Now()
+	before, err := ioutil.ReadFile(seedFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	select {
+	case <-acc.stopSources:
+	case <-time.After(time.Second):
+		t.Fatal("cancelling the context did not close the Accumulator")
+	}
+	// wait for the entropy sources to stop and for the close to finish
+	acc.sources.Wait()
+	err = acc.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	after, err := ioutil.ReadFile(seedFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != seedFileSize || bytes.Compare(before, after) == 0 {
+		t.Error("seed file not written on cancellation")
+	}
+}
+
 func TestReseedingDuringClose(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -141,6 +231,97 @@ func TestReseedingDuringClose(t *testing.T) {
 	acc.Close()
 }
 
+func TestMaxReadSize(t *testing.T) {
+	acc, _ := NewRNG("")
+	buf := make([]byte, 100)
+
+	n, err := acc.Read(buf)
+	if n != 100 || err != nil {
+		t.Errorf("unlimited read failed: %d, %v", n, err)
+	}
+
+	acc.SetMaxReadSize(64)
+	n, err = acc.Read(buf[:64])
+	if n != 64 || err != nil {
+		t.Errorf("read at the limit failed: %d, %v", n, err)
+	}
+	n, err = acc.Read(buf)
+	if n != 0 || err != ErrReadTooLarge {
+		t.Errorf("over-size read not rejected: %d, %v", n, err)
+	}
+
+	acc.SetTruncateReads(true)
+	n, err = acc.Read(buf)
+	if n != 64 || err != nil {
+		t.Errorf("over-size read not truncated: %d, %v", n, err)
+	}
+
+	acc.SetMaxReadSize(0)
+	n, err = acc.Read(buf)
+	if n != 100 || err != nil {
+		t.Errorf("removing the limit failed: %d, %v", n, err)
+	}
+}
+
+func TestAccumulatorReseedChangesState(t *testing.T) {
+	acc, _ := NewRNG("")
+	defer acc.Close()
+
+	// Without entropy, a draw only rekeys the generator.
+	unchanged := acc.gen.clone()
+	acc.RandomData(16)
+	unchanged.PseudoRandomData(16)
+	if !bytes.Equal(acc.gen.stateHash(), unchanged.stateHash()) {
+		t.Fatal("generator state changed without reseed")
+	}
+
+	acc.addRandomEvent(0, 0, make([]byte, minPoolSize))
+	x := acc.RandomData(1024)
+	y := unchanged.PseudoRandomData(1024)
+	if acc.reseedCount != 1 {
+		t.Fatal("accumulator did not reseed")
+	}
+	if bytes.Equal(acc.gen.stateHash(), unchanged.stateHash()) {
+		t.Error("accumulator reseed did not change the generator state")
+	}
+	if d := bitDifference(x, y); d < 0.45 || d > 0.55 {
+		t.Errorf("output correlated with old output (%.3f bits differ)", d)
+	}
+}
+
+func TestReadAtomic(t *testing.T) {
+	acc, _ := NewRNG("")
+	defer acc.Close()
+
+	limit := maxBlocks * aes.BlockSize
+	buf := make([]byte, limit+1)
+	n, err := acc.ReadAtomic(buf)
+	if n != 0 || err != ErrNotAtomic {
+		t.Errorf("oversize atomic read: %d, %v", n, err)
+	}
+
+	// Entropy is pending, so that the read is preceded by a reseed.
+	acc.addRandomEvent(0, 0, make([]byte, minPoolSize))
+	acc.genMutex.Lock()
+	ref := acc.gen.clone()
+	acc.genMutex.Unlock()
+	ref.Reseed(acc.pool[0].Sum(nil))
+
+	n, err = acc.ReadAtomic(buf[:limit])
+	if n != limit || err != nil {
+		t.Fatalf("atomic read failed: %d, %v", n, err)
+	}
+	if acc.reseedCount != 1 {
+		t.Fatal("reseed did not happen")
+	}
+	// All data must come from one key, i.e. from a single run of
+	// the block cipher in counter mode.
+	expected := ref.generateBlocks(nil, maxBlocks)
+	if !bytes.Equal(buf[:limit], expected) {
+		t.Error("atomic read used more than one key")
+	}
+}
+
 func accumulatorRead(b *testing.B, n int) {
 	acc, _ := NewRNG("")
 	buffer := make([]byte, n)
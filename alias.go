@@ -0,0 +1,102 @@
+// alias.go - sampling from discrete distributions
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrInvalidWeights is returned by NewAliasSampler if the weights do
+// not describe a probability distribution.
+var ErrInvalidWeights = errors.New("invalid weights")
+
+// AliasSampler draws samples from a fixed discrete distribution on
+// 0, 1, ..., n-1, using Walker's alias method.  Construction takes
+// O(n) time, after which every sample takes O(1) time, independent
+// of the number of categories.  An AliasSampler is not modified by
+// sampling and can be shared between goroutines, as long as every
+// goroutine uses its own Generator.
+type AliasSampler struct {
+	prob  []float64
+	alias []int
+}
+
+// NewAliasSampler returns an AliasSampler for the distribution where
+// i has probability proportional to weights[i].  The weights must be
+// finite and non-negative, and at least one weight must be positive;
+// otherwise ErrInvalidWeights is returned.
+func NewAliasSampler(weights []float64) (*AliasSampler, error) {
+	n := len(weights)
+	total := 0.0
+	for _, w := range weights {
+		if w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+			return nil, ErrInvalidWeights
+		}
+		total += w
+	}
+	if !(total > 0) || math.IsInf(total, 0) {
+		return nil, ErrInvalidWeights
+	}
+
+	// Vose's variant of the construction: scale the weights to mean
+	// 1, then repeatedly fill up a small column using a large one.
+	prob := make([]float64, n)
+	alias := make([]int, n)
+	var small, large []int
+	for i, w := range weights {
+		prob[i] = w * float64(n) / total
+		if prob[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s := small[len(small)-1]
+		small = small[:len(small)-1]
+		l := large[len(large)-1]
+
+		alias[s] = l
+		prob[l] -= 1 - prob[s]
+		if prob[l] < 1 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// Because of rounding, columns can be left over in either list.
+	// These are full.
+	for _, i := range large {
+		prob[i] = 1
+	}
+	for _, i := range small {
+		prob[i] = 1
+	}
+
+	return &AliasSampler{prob: prob, alias: alias}, nil
+}
+
+// Sample returns a random sample from the distribution, using gen as
+// the source of randomness.  Every sample uses one uniformly chosen
+// column and one uniform float64.
+func (s *AliasSampler) Sample(gen *Generator) int {
+	i := gen.intn(len(s.prob))
+	if gen.float64() < s.prob[i] {
+		return i
+	}
+	return s.alias[i]
+}
@@ -0,0 +1,116 @@
+// alias_test.go - unit tests for alias.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/aes"
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestAliasSampler(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+
+	weights := []float64{1, 0, 2, 3, 0.5, 10, 3.5}
+	total := 20.0
+	s, err := NewAliasSampler(weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 100000
+	counts := make([]int, len(weights))
+	for i := 0; i < n; i++ {
+		counts[s.Sample(gen)]++
+	}
+	for i, w := range weights {
+		p := w / total
+		sd := math.Sqrt(p*(1-p)*float64(n)) + 1e-9
+		d := (float64(counts[i]) - p*float64(n)) / sd
+		if math.Abs(d) >= 4 {
+			t.Errorf("category %d: %d samples, expected %.0f",
+				i, counts[i], p*float64(n))
+		}
+	}
+
+	for _, bad := range [][]float64{
+		nil,
+		{0, 0},
+		{1, -1},
+		{1, math.NaN()},
+		{1, math.Inf(1)},
+	} {
+		_, err := NewAliasSampler(bad)
+		if err != ErrInvalidWeights {
+			t.Errorf("%v: expected ErrInvalidWeights, got %v", bad, err)
+		}
+	}
+}
+
+func benchmarkWeights() []float64 {
+	weights := make([]float64, 10000)
+	for i := range weights {
+		weights[i] = 1 / float64(i+1)
+	}
+	return weights
+}
+
+func BenchmarkAliasSampler(b *testing.B) {
+	gen := NewGenerator(aes.NewCipher)
+	s, _ := NewAliasSampler(benchmarkWeights())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Sample(gen)
+	}
+}
+
+func BenchmarkCDFSearch(b *testing.B) {
+	gen := NewGenerator(aes.NewCipher)
+	weights := benchmarkWeights()
+	cdf := make([]float64, len(weights))
+	total := 0.0
+	for i, w := range weights {
+		total += w
+		cdf[i] = total
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		u := gen.float64() * total
+		sort.SearchFloat64s(cdf, u)
+	}
+}
+
+func BenchmarkLinearSearch(b *testing.B) {
+	gen := NewGenerator(aes.NewCipher)
+	weights := benchmarkWeights()
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		u := gen.float64() * total
+		for _, w := range weights {
+			u -= w
+			if u < 0 {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// bitmatrix.go - random matrices of bits
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+// BitMatrix is a matrix of bits, stored in packed form.  Each row is
+// stored in Stride = (Cols+7)/8 consecutive bytes of Data, starting
+// at Data[i*Stride] for row i.  Within a row, the bit in column j is
+// bit j%8 of byte j/8, where bit 0 is the least significant bit.
+// Unused bits at the end of each row are zero.
+type BitMatrix struct {
+	Rows, Cols int
+	Stride     int
+	Data       []byte
+}
+
+// Bit returns the bit in row i and column j of the matrix.
+func (m *BitMatrix) Bit(i, j int) bool {
+	if i < 0 || i >= m.Rows || j < 0 || j >= m.Cols {
+		panic("index out of range")
+	}
+	return m.Data[i*m.Stride+j/8]&(1<<uint(j%8)) != 0
+}
+
+// RandomBitMatrix returns a matrix with the given number of rows and
+// columns, where every bit is random.  The bits are independent and
+// each bit is set with probability 1/2.  All random bits are
+// generated by a single call to .PseudoRandomData().
+func (gen *Generator) RandomBitMatrix(rows, cols int) *BitMatrix {
+	if rows < 0 || cols < 0 {
+		panic("negative matrix size")
+	}
+	stride := (cols + 7) / 8
+	data := gen.PseudoRandomData(uint(rows * stride))
+	if extra := uint(8*stride - cols); extra > 0 {
+		mask := byte(0xff >> extra)
+		for i := stride - 1; i < len(data); i += stride {
+			data[i] &= mask
+		}
+	}
+	return &BitMatrix{
+		Rows:   rows,
+		Cols:   cols,
+		Stride: stride,
+		Data:   data,
+	}
+}
@@ -0,0 +1,82 @@
+// bitmatrix_test.go - unit tests for bitmatrix.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/aes"
+	"math"
+	"testing"
+)
+
+func TestRandomBitMatrix(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+
+	rows, cols := 300, 301
+	m := gen.RandomBitMatrix(rows, cols)
+	if m.Rows != rows || m.Cols != cols || m.Stride != 38 ||
+		len(m.Data) != rows*m.Stride {
+		t.Fatalf("wrong dimensions %d, %d, %d, %d",
+			m.Rows, m.Cols, m.Stride, len(m.Data))
+	}
+
+	count := 0
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if m.Bit(i, j) {
+				count++
+			}
+		}
+		if m.Data[i*m.Stride+m.Stride-1]&0xe0 != 0 {
+			t.Errorf("padding bits set in row %d", i)
+		}
+	}
+	n := float64(rows * cols)
+	d := (float64(count) - 0.5*n) / math.Sqrt(0.25*n)
+	if math.Abs(d) >= 4 {
+		t.Errorf("%d out of %.0f bits set", count, n)
+	}
+
+	m = gen.RandomBitMatrix(0, 5)
+	if len(m.Data) != 0 {
+		t.Error("wrong data for empty matrix")
+	}
+}
+
+func BenchmarkRandomBitMatrix(b *testing.B) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		gen.RandomBitMatrix(64, 64)
+	}
+}
+
+func BenchmarkRandomBitsSingly(b *testing.B) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := make([][]bool, 64)
+		for j := range m {
+			m[j] = make([]bool, 64)
+			for k := range m[j] {
+				m[j][k] = gen.PseudoRandomData(1)[0]&1 != 0
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// ciphers.go - block ciphers known to work with the generator
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/aes"
+)
+
+// CipherInfo describes a block cipher which can be used with the
+// Fortuna generator.
+type CipherInfo struct {
+	// Name is a human-readable name for the cipher.
+	Name string
+
+	// New is the function used to allocate a new instance of the
+	// cipher.  It can be passed to NewGenerator() and
+	// NewAccumulator().
+	New NewCipher
+
+	// KeySize and BlockSize give the key size and the block size of
+	// the cipher in bytes.
+	KeySize, BlockSize int
+}
+
+// SupportedCiphers returns the list of block ciphers which are tested
+// with this package.
+//
+// The generator always uses 32-byte keys, so only ciphers which
+// accept keys of this size can be used.  For AES this means that
+// AES-256 is used; AES-128 and AES-192 are not available.  Other
+// ciphers with 256-bit keys, for example Serpent or Twofish, can be
+// used but are not bundled with this package and thus are not listed
+// here.
+func SupportedCiphers() []CipherInfo {
+	return []CipherInfo{
+		{
+			Name:      "AES-256",
+			New:       aes.NewCipher,
+			KeySize:   keySize,
+			BlockSize: aes.BlockSize,
+		},
+	}
+}
@@ -0,0 +1,49 @@
+// ciphers_test.go - unit tests for ciphers.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"testing"
+)
+
+func TestSupportedCiphers(t *testing.T) {
+	ciphers := SupportedCiphers()
+	if len(ciphers) == 0 {
+		t.Fatal("no ciphers listed")
+	}
+	for _, info := range ciphers {
+		if info.KeySize != keySize {
+			t.Errorf("%s: wrong key size %d", info.Name, info.KeySize)
+		}
+
+		block, err := info.New(make([]byte, info.KeySize))
+		if err != nil {
+			t.Errorf("%s: %v", info.Name, err)
+			continue
+		}
+		if block.BlockSize() != info.BlockSize {
+			t.Errorf("%s: wrong block size %d", info.Name, info.BlockSize)
+		}
+
+		gen := NewGenerator(info.New)
+		gen.Seed(1)
+		out := gen.PseudoRandomData(100)
+		if len(out) != 100 || isZero(out) {
+			t.Errorf("%s: generator does not work", info.Name)
+		}
+	}
+}
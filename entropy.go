@@ -18,6 +18,7 @@ package fortuna
 
 import (
 	"crypto/sha256"
+	"runtime"
 	"time"
 )
 
@@ -40,14 +41,63 @@ func (acc *Accumulator) addRandomEvent(source uint8, seq uint, data []byte) {
 	acc.poolMutex.Lock()
 	defer acc.poolMutex.Unlock()
 
+	acc.lastEvent = acc.clock()
 	poolHash := acc.pool[pool]
 	poolHash.Write([]byte{source, byte(len(data))})
 	poolHash.Write(data)
+	acc.poolSize[pool] += 2 + len(data)
 	if pool == 0 {
-		acc.poolZeroSize += 2 + len(data)
+		acc.poolZeroSources[source] += 2 + len(data)
 	}
 }
 
+// poolZeroSize returns the amount of data in pool 0 which counts
+// towards the threshold for reseeding.  If a maximal source fraction
+// is set, the data from each source only counts up to the
+// corresponding limit.  The caller must hold acc.poolMutex.
+func (acc *Accumulator) poolZeroSize() int {
+	f := acc.maxSourceFraction
+	if f <= 0 || f >= 1 {
+		return acc.poolSize[0]
+	}
+
+	limit := int(f * minPoolSize)
+	size := 0
+	for _, n := range acc.poolZeroSources {
+		if n > limit {
+			n = limit
+		}
+		size += n
+	}
+	return size
+}
+
+// SetMaxSourceFraction limits the influence of a single entropy
+// source on the timing of reseeds.  Normally, the generator is
+// reseeded once enough data has been submitted to pool 0.  If f < 1,
+// the data from each source only counts up to a fraction f of this
+// threshold, so that data from at least 1/f different sources is
+// required before a reseed can happen.  Since the threshold is 32
+// bytes, f must be at least 1/32; smaller values cause a panic.
+// Values of 1 or larger (the default) remove the limit.
+//
+// The limit only affects when a reseed happens.  It does not limit
+// the amount of data a source contributes to a reseed: everything
+// submitted is still hashed into the pools in full.  The multi-pool
+// design of Fortuna already ensures that a single attacker-controlled
+// source cannot prevent eventual recovery from a state compromise.
+// Limiting the influence of single sources additionally stops such a
+// source from triggering reseeds on its own, at a time when the pools
+// contain little data from honest sources.
+func (acc *Accumulator) SetMaxSourceFraction(f float64) {
+	if !(f >= 1.0/minPoolSize) {
+		panic("source fraction must be at least 1/32")
+	}
+	acc.poolMutex.Lock()
+	defer acc.poolMutex.Unlock()
+	acc.maxSourceFraction = f
+}
+
 // allocateSource allocates a new source index for an entropy source.
 func (acc *Accumulator) allocateSource() uint8 {
 	acc.sourceMutex.Lock()
@@ -149,3 +199,81 @@ func (acc *Accumulator) NewEntropyTimeStampSink() chan<- time.Time {
 
 	return c
 }
+
+// runtimeSample returns the SHA-256 hash of a snapshot of runtime
+// statistics: the current time, heap and allocation counters, garbage
+// collector timings and the number of goroutines.
+func runtimeSample() []byte {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	hash := sha256.New()
+	for _, x := range []uint64{
+		uint64(time.Now().UnixNano()),
+		ms.HeapAlloc, ms.HeapSys, ms.HeapObjects,
+		ms.Mallocs, ms.Frees, ms.TotalAlloc,
+		uint64(ms.NumGC), ms.PauseTotalNs, ms.LastGC,
+		ms.PauseNs[(ms.NumGC+255)%256],
+		uint64(runtime.NumGoroutine()),
+	} {
+		hash.Write(uint64ToBytes(x))
+	}
+	return hash.Sum(nil)
+}
+
+// AddRuntimeSource starts a background goroutine which, once every
+// interval, samples statistics of the Go runtime (heap sizes,
+// allocation counts, garbage collector pause times and the number of
+// goroutines, together with the current time) and submits a hash of
+// these to the Accumulator's entropy pools.  The goroutine stops when
+// the Accumulator is closed.
+//
+// The entropy of these samples is low: an attacker who knows the
+// program and its workload can predict much of the data, and
+// consecutive samples are strongly correlated.  The source is meant
+// as an opportunistic supplement for long-running processes, not as
+// a replacement for proper entropy sources.  Since
+// runtime.ReadMemStats() briefly stops the world, the interval should
+// not be chosen too small; one second or more is reasonable.
+func (acc *Accumulator) AddRuntimeSource(interval time.Duration) {
+	source := acc.allocateSource()
+
+	acc.sources.Add(1)
+	go func() {
+		defer acc.sources.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for seq := uint(0); ; seq++ {
+			select {
+			case <-ticker.C:
+			case <-acc.stopSources:
+				return
+			}
+			acc.addRandomEvent(source, seq, runtimeSample())
+		}
+	}()
+}
+
+// EntropyStarved reports whether random output has been drawn from
+// the Accumulator after no new entropy has been submitted for longer
+// than the starvation threshold (10 minutes by default, see
+// .SetStarvationThreshold()).  In this situation, reseeds happen only
+// rarely or not at all, and the Accumulator cannot recover from a
+// compromise of the generator state.  A true result indicates that
+// the entropy sources of the program are not working as intended.
+// The condition is cleared once new entropy is submitted.
+func (acc *Accumulator) EntropyStarved() bool {
+	acc.poolMutex.Lock()
+	defer acc.poolMutex.Unlock()
+	return acc.lastDraw.Sub(acc.lastEvent) > acc.starvationThreshold
+}
+
+// SetStarvationThreshold sets the time without new entropy after which
+// drawing output from the Accumulator is considered a sign of entropy
+// starvation.  See .EntropyStarved() for details.
+func (acc *Accumulator) SetStarvationThreshold(d time.Duration) {
+	acc.poolMutex.Lock()
+	defer acc.poolMutex.Unlock()
+	acc.starvationThreshold = d
+}
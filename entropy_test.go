@@ -17,6 +17,8 @@
 package fortuna
 
 import (
+	"bytes"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -30,7 +32,7 @@ func TestPoolSelection(t *testing.T) {
 		sink <- msg
 	}
 	acc.poolMutex.Lock()
-	size := acc.poolZeroSize
+	size := acc.poolSize[0]
 	acc.poolMutex.Unlock()
 
 	if size != 2*(2+len(msg)) {
@@ -38,6 +40,132 @@ func TestPoolSelection(t *testing.T) {
 	}
 }
 
+func TestMaxSourceFraction(t *testing.T) {
+	acc, _ := NewRNG("")
+	acc.SetMaxSourceFraction(0.5)
+
+	// A single source cannot trigger a reseed on its own ...
+	for i := 0; i < 100; i++ {
+		acc.addRandomEvent(0, 0, make([]byte, 32))
+	}
+	acc.RandomData(1)
+	if acc.reseedCount != 0 {
+		t.Error("single source triggered a reseed")
+	}
+
+	// ... but with a second source a reseed happens.
+	acc.addRandomEvent(1, 0, make([]byte, 6))
+	acc.RandomData(1)
+	if acc.reseedCount != 0 {
+		t.Error("reseed triggered too early")
+	}
+	acc.addRandomEvent(1, 0, make([]byte, 6))
+	acc.RandomData(1)
+	if acc.reseedCount != 1 {
+		t.Error("two sources failed to trigger a reseed")
+	}
+
+	// After the reseed, the contributions are counted afresh.
+	time.Sleep(2 * minReseedInterval)
+	for i := 0; i < 100; i++ {
+		acc.addRandomEvent(0, 0, make([]byte, 32))
+	}
+	acc.RandomData(1)
+	if acc.reseedCount != 1 {
+		t.Error("single source triggered a reseed")
+	}
+}
+
+func TestMinSourceFraction(t *testing.T) {
+	acc, _ := NewRNG("")
+	acc.SetMaxSourceFraction(1.0 / minPoolSize)
+
+	// Each source counts for at most one byte, so 32 sources are
+	// needed for a reseed.
+	for source := 0; source < minPoolSize; source++ {
+		acc.RandomData(1)
+		if acc.reseedCount != 0 {
+			t.Fatalf("reseed after %d sources", source)
+		}
+		acc.addRandomEvent(uint8(source), 0, make([]byte, 32))
+	}
+	acc.RandomData(1)
+	if acc.reseedCount != 1 {
+		t.Error("32 sources failed to trigger a reseed")
+	}
+
+	for _, f := range []float64{0.01, 0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("source fraction %g accepted", f)
+				}
+			}()
+			acc.SetMaxSourceFraction(f)
+		}()
+	}
+}
+
+func TestEntropyStarved(t *testing.T) {
+	acc, _ := NewRNG("")
+	now := time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC)
+	acc.clock = func() time.Time { return now }
+	acc.addRandomEvent(0, 0, []byte{1})
+	acc.SetStarvationThreshold(time.Minute)
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(10 * time.Second)
+		acc.RandomData(1)
+		starved := acc.EntropyStarved()
+		if starved != (i >= 6) {
+			t.Errorf("wrong state %t after %d draws", starved, i+1)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if !acc.EntropyStarved() {
+		t.Error("starvation cleared without new entropy")
+	}
+	acc.addRandomEvent(0, 1, []byte{2})
+	if acc.EntropyStarved() {
+		t.Error("starvation not cleared by new entropy")
+	}
+	now = now.Add(time.Hour)
+	if acc.EntropyStarved() {
+		t.Error("starvation reported without drawing output")
+	}
+}
+
+var runtimeSink [][]byte
+
+func TestRuntimeSource(t *testing.T) {
+	var samples [][]byte
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 100; j++ {
+			runtimeSink = append(runtimeSink, make([]byte, 1000))
+		}
+		if i%3 == 0 {
+			runtime.GC()
+		}
+		sample := runtimeSample()
+		for _, old := range samples {
+			if bytes.Equal(sample, old) {
+				t.Fatal("repeated runtime sample")
+			}
+		}
+		samples = append(samples, sample)
+	}
+	runtimeSink = nil
+
+	acc, _ := NewRNG("")
+	acc.AddRuntimeSource(time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if size := poolTotal(acc); size < 2*(2+32) {
+		t.Errorf("too little runtime data submitted: %d bytes", size)
+	}
+	acc.Close()
+}
+
 func BenchmarkAddRandomEvent(b *testing.B) {
 	acc, _ := NewRNG("")
 	source := acc.allocateSource()
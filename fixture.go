@@ -0,0 +1,60 @@
+// fixture.go - reproducible test data
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/aes"
+	"io"
+)
+
+// WriteFixture writes n pseudo-random bytes, determined by seed, to
+// w.  This is meant for generating test data: the output only
+// depends on seed and n, and is the same on all architectures and
+// for all runs of the program.  The data written is identical to the
+// output of the following code:
+//
+//     gen := fortuna.NewGenerator(aes.NewCipher)
+//     gen.Seed(seed)
+//     data := gen.PseudoRandomData(n)
+//
+// Large fixtures are generated in chunks, so that the memory used by
+// WriteFixture does not grow with n.  The output must not be used for
+// cryptographic purposes, since anybody who knows the seed can
+// reproduce it.
+func WriteFixture(w io.Writer, seed int64, n uint) error {
+	gen := &Generator{
+		newCipher: aes.NewCipher,
+	}
+	gen.Seed(seed)
+	defer gen.Close()
+
+	// Chunks of maxBlocks blocks end exactly where PseudoRandomData
+	// would rekey anyway, so chunking does not change the output.
+	chunkSize := uint(maxBlocks * aes.BlockSize)
+	for n > 0 {
+		k := n
+		if k > chunkSize {
+			k = chunkSize
+		}
+		_, err := w.Write(gen.PseudoRandomData(k))
+		if err != nil {
+			return err
+		}
+		n -= k
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// fixture_test.go - unit tests for fixture.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+)
+
+func TestWriteFixture(t *testing.T) {
+	for _, n := range []uint{0, 100, 3*maxBlocks*16 + 7} {
+		buf1 := &bytes.Buffer{}
+		err := WriteFixture(buf1, 42, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf2 := &bytes.Buffer{}
+		err = WriteFixture(buf2, 42, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if uint(buf1.Len()) != n {
+			t.Errorf("wrong fixture length %d, expected %d", buf1.Len(), n)
+		}
+		if bytes.Compare(buf1.Bytes(), buf2.Bytes()) != 0 {
+			t.Errorf("fixture of length %d not reproducible", n)
+		}
+
+		gen := NewGenerator(aes.NewCipher)
+		gen.Seed(42)
+		if bytes.Compare(buf1.Bytes(), gen.PseudoRandomData(n)) != 0 {
+			t.Errorf("fixture of length %d differs from generator output", n)
+		}
+	}
+}
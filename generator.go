@@ -20,11 +20,15 @@ import (
 	"bytes"
 	"crypto/cipher"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net"
+	"os"
 	"os/user"
+	"sync"
 	"time"
 
 	"github.com/seehuhn/sha256d"
@@ -39,6 +43,28 @@ const (
 	keySize = sha256d.Size
 )
 
+var (
+	// ErrIncompatibleGenerators is returned by Combine() if the two
+	// generators use block ciphers with different block sizes.
+	ErrIncompatibleGenerators = errors.New("generators use incompatible ciphers")
+
+	// ErrNotSeeded is returned by Generator.Read() if the generator
+	// has not been seeded.
+	ErrNotSeeded = errors.New("Fortuna generator not yet seeded")
+
+	// ErrClosed is returned by Generator.Read() if the generator has
+	// been closed.
+	ErrClosed = errors.New("Fortuna generator closed")
+
+	// ErrZeroSeed is the panic value of Generator.Seed() if a zero
+	// seed is used while strict seeding is enabled.
+	ErrZeroSeed = errors.New("Fortuna generator seeded with zero")
+
+	// ErrLive is the panic value of Generator.Seed() if the generator
+	// has been switched to live mode using Generator.GoLive().
+	ErrLive = errors.New("Fortuna generator is live, cannot use Seed()")
+)
+
 // NewCipher is the type which represents the function to allocate a
 // new block cipher.  A typical example of a function of this type is
 // aes.NewCipher.
@@ -58,6 +84,29 @@ type Generator struct {
 	key       []byte
 	cipher    cipher.Block
 	counter   []byte
+
+	autoSeed   bool
+	strictSeed bool
+	live       bool
+	closed     bool
+	salts      *recentSet
+	nonces     *recentSet
+
+	lockedMem     []byte
+	zeroizePasses int
+
+	// clock is used by .UUIDv7(); if nil, time.Now is used.
+	clock func() time.Time
+
+	bufPool *sync.Pool
+	// bufHeaders holds the *[]byte values of buffers taken from
+	// bufPool, for reuse by .ReturnBuffer().
+	bufHeaders []*[]byte
+
+	blockBytes    uint64
+	bytesReturned uint64
+
+	postProcess func(block []byte)
 }
 
 func (gen *Generator) inc() {
@@ -71,11 +120,21 @@ func (gen *Generator) inc() {
 	}
 }
 
+// setKey installs a new key for the generator.  The key is copied
+// into the generator's key buffer, so that the key material always
+// stays in the same memory location, and the argument is overwritten
+// with zeros.
 func (gen *Generator) setKey(key []byte) {
 	if len(key) != keySize {
 		panic("wrong key size")
 	}
-	gen.key = key
+	if gen.key == nil {
+		gen.key = make([]byte, keySize)
+	} else {
+		gen.zeroize(gen.key)
+	}
+	copy(gen.key, key)
+	gen.zeroize(key)
 	cipher, err := gen.newCipher(gen.key)
 	if err != nil {
 		panic("newCipher() failed, cannot set generator key")
@@ -83,6 +142,38 @@ func (gen *Generator) setKey(key []byte) {
 	gen.cipher = cipher
 }
 
+// zeroizeFill is used by .zeroize() to overwrite key material with
+// random bytes.  This is a variable so that the tests can observe the
+// overwrite passes.
+var zeroizeFill = func(data []byte) {
+	io.ReadFull(rand.Reader, data)
+}
+
+// zeroize overwrites data using the number of passes set by
+// .SetZeroizePasses().  All passes except the last one write random
+// bytes, the final pass writes zeros.
+func (gen *Generator) zeroize(data []byte) {
+	for i := 1; i < gen.zeroizePasses; i++ {
+		zeroizeFill(data)
+	}
+	wipe(data)
+}
+
+// SetZeroizePasses sets the number of times key material is
+// overwritten when it is discarded, i.e. when the key is replaced
+// during rekeying or reseeding, and when the generator is closed.
+// The first n-1 passes write random data, the final pass writes
+// zeros.  The default is a single pass of zeros; values of n smaller
+// than 1 restore this default.
+//
+// On modern hardware, a single pass is sufficient to make the old
+// data unrecoverable.  Additional passes are only useful where a
+// compliance regime requires them, and slow down every rekey of the
+// generator.
+func (gen *Generator) SetZeroizePasses(n int) {
+	gen.zeroizePasses = n
+}
+
 // setInitialSeed sets the initial seed for the Generator.  An
 // attempt is made to obtain seeds which differ between machines and
 // between reboots.  To achieve this, the following information is
@@ -174,6 +265,51 @@ func NewGenerator(newCipher NewCipher) *Generator {
 	return gen
 }
 
+// Combine returns a new generator whose state is derived from the
+// states of both a and b.  The new key is obtained by hashing the
+// keys and counters of a and b together.  The output of the new
+// generator is a deterministic function of the states of a and b, and
+// a and b themselves are not modified.  The new generator uses the
+// block cipher of a; if the block sizes of the ciphers used by a and b
+// differ, ErrIncompatibleGenerators is returned.
+//
+// The order of the arguments matters: in general, Combine(a, b) and
+// Combine(b, a) produce different output.  Parties which combine their
+// generators independently must agree on the order.
+func Combine(a, b *Generator) (*Generator, error) {
+	if len(a.counter) != len(b.counter) {
+		return nil, ErrIncompatibleGenerators
+	}
+
+	seed := make([]byte, 0, 2*(keySize+len(a.counter)))
+	seed = append(seed, a.key...)
+	seed = append(seed, a.counter...)
+	seed = append(seed, b.key...)
+	seed = append(seed, b.counter...)
+
+	gen := &Generator{
+		newCipher: a.newCipher,
+	}
+	gen.reset()
+	gen.Reseed(seed)
+	wipe(seed)
+
+	return gen, nil
+}
+
+// clone returns an independent copy of the generator, in the same
+// state as gen.
+func (gen *Generator) clone() *Generator {
+	res := &Generator{
+		newCipher:     gen.newCipher,
+		counter:       append([]byte(nil), gen.counter...),
+		zeroizePasses: gen.zeroizePasses,
+		postProcess:   gen.postProcess,
+	}
+	res.setKey(append([]byte(nil), gen.key...))
+	return res
+}
+
 // reset reverts the generator to the unseeded state.  A new seed must
 // be set using the .Reseed() or .Seed() methods before the generator
 // can be used again.  This is mostly useful for unit testing, to
@@ -216,7 +352,7 @@ func (gen *Generator) ReseedInt64(seed int64) {
 // size of the underlying cipher, i.e. 16 bytes for AES.
 func (gen *Generator) generateBlocks(data []byte, k uint) []byte {
 	if isZero(gen.counter) {
-		panic("Fortuna generator not yet seeded")
+		panic(ErrNotSeeded)
 	}
 
 	buf := make([]byte, len(gen.counter))
@@ -229,33 +365,332 @@ func (gen *Generator) generateBlocks(data []byte, k uint) []byte {
 	return data
 }
 
+// generateOutput is like .generateBlocks(), but applies the function
+// set by .SetBlockPostProcess() to every block.  This is used for
+// blocks which are returned to the caller, but not for blocks used as
+// keys.
+func (gen *Generator) generateOutput(data []byte, k uint) []byte {
+	start := len(data)
+	data = gen.generateBlocks(data, k)
+	if gen.postProcess != nil {
+		blockSize := len(gen.counter)
+		for i := start; i < len(data); i += blockSize {
+			gen.postProcess(data[i : i+blockSize])
+		}
+	}
+	return data
+}
+
+// SetBlockPostProcess installs a function which is applied, in place,
+// to every block of output before it is returned.  The function is
+// not applied to the blocks used to generate new keys, so the internal
+// state of the generator is not affected.  This allows constructions
+// which require output to pass through an approved mixing function.
+// If f is nil, which is the default, blocks are returned unchanged.
+//
+// Post-processing changes the output of the generator.  Reproducible
+// output, e.g. after .Seed(), is only obtained if f is deterministic.
+// A function which is not a bijection can reduce the quality of the
+// output.
+func (gen *Generator) SetBlockPostProcess(f func(block []byte)) {
+	gen.postProcess = f
+}
+
 func (gen *Generator) numBlocks(n uint) uint {
 	k := uint(len(gen.counter))
 	return (n + k - 1) / k
 }
 
+// taggedPrefix is prepended to the tags used by
+// .PseudoRandomDataTagged(), to separate these from ordinary seeds.
+var taggedPrefix = []byte("fortuna tagged output\x00")
+
+// PseudoRandomDataTagged returns n pseudo-random bytes, derived from
+// the current generator state and the given tag.  The state of the
+// generator is not modified: the output is obtained from a temporary
+// copy of the generator, reseeded with the tag.  Different tags yield
+// independent streams, which are also independent from the output of
+// .PseudoRandomData().  Calling the method repeatedly with the same
+// tag, without using the generator in between, returns the same data.
+//
+// This allows to derive randomness for different purposes, for
+// example different keys of a protocol, from one generator state.
+//
+// Unlike the output of .PseudoRandomData(), tagged output is not
+// forward-secure on its own: it can be recomputed from the current key
+// and counter until the generator is next used, so that anybody who
+// captures the generator state before then can recover it.  Callers
+// who need forward secrecy, e.g. because the output is used as a
+// long-term key, must draw data from the generator, for example using
+// .PseudoRandomData(), after obtaining the tagged output.
+func (gen *Generator) PseudoRandomDataTagged(tag []byte, n uint) []byte {
+	if err := gen.checkSeeded(); err != nil {
+		panic(err)
+	}
+
+	tmp := gen.clone()
+	defer tmp.Close()
+	seed := make([]byte, 0, len(taggedPrefix)+len(tag))
+	seed = append(seed, taggedPrefix...)
+	seed = append(seed, tag...)
+	tmp.Reseed(seed)
+	return tmp.PseudoRandomData(n)
+}
+
+// SetAutoSeed determines what happens when random data is requested
+// from a generator which has not been seeded.  If autoSeed is false,
+// which is the default, .PseudoRandomData() panics and .Read() returns
+// ErrNotSeeded.  If autoSeed is true, the generator is instead seeded
+// automatically with data from the crypto/rand package.  Since this
+// makes the output unpredictable, auto-seeding should only be enabled
+// where reproducible output is not required.
+func (gen *Generator) SetAutoSeed(autoSeed bool) {
+	gen.autoSeed = autoSeed
+}
+
+// checkSeeded makes sure that the generator is seeded, seeding it
+// automatically if this is enabled.  If the generator is not seeded
+// after the call, or if the generator has been closed, a non-nil
+// error is returned.
+func (gen *Generator) checkSeeded() error {
+	if gen.closed {
+		return ErrClosed
+	}
+	if !isZero(gen.counter) {
+		return nil
+	}
+	if !gen.autoSeed {
+		return ErrNotSeeded
+	}
+
+	seed := make([]byte, keySize)
+	_, err := io.ReadFull(rand.Reader, seed)
+	if err != nil {
+		return err
+	}
+	gen.Reseed(seed)
+	wipe(seed)
+	return nil
+}
+
 // PseudoRandomData returns a slice of n pseudo-random bytes.  The
 // result can be used as a replacement for a sequence of n uniformly
 // distributed and independent bytes.
+//
+// Unused bytes from the final cipher block are discarded and the key
+// is replaced before the method returns, so that no output generated
+// for one call is ever kept around for later calls.  This costs a
+// little performance for short requests, but guarantees that a
+// compromise of the generator state cannot reveal earlier output.
+// Since the key used for a call is discarded before the call returns,
+// the output of every single call is forward-secure and can be used
+// for one-time secrets directly; the price is one rekey, i.e. the
+// generation of two additional cipher blocks, per call.
 func (gen *Generator) PseudoRandomData(n uint) []byte {
+	if err := gen.checkSeeded(); err != nil {
+		panic(err)
+	}
+
 	numBlocks := gen.numBlocks(n)
-	res := make([]byte, 0, numBlocks*uint(len(gen.counter)))
+	res := gen.getBuffer(numBlocks * uint(len(gen.counter)))
+	gen.blockBytes += uint64(numBlocks) * uint64(len(gen.counter))
+	gen.bytesReturned += uint64(n)
 
 	for numBlocks > 0 {
 		count := numBlocks
 		if count > maxBlocks {
 			count = maxBlocks
 		}
-		res = gen.generateBlocks(res, count)
+		res = gen.generateOutput(res, count)
 		numBlocks -= count
 
 		newKey := gen.generateBlocks(nil, gen.numBlocks(keySize))
 		gen.setKey(newKey[:keySize])
 	}
 
+	// Clear the unused part of the final block, so that it cannot
+	// be reached by extending the returned slice.
+	wipe(res[n:])
 	return res[:n]
 }
 
+// getBuffer returns an empty slice with capacity at least size, taken
+// from the buffer pool if one is set.
+func (gen *Generator) getBuffer(size uint) []byte {
+	if gen.bufPool != nil {
+		ptr, ok := gen.bufPool.Get().(*[]byte)
+		if ok {
+			buf := *ptr
+			*ptr = nil
+			gen.bufHeaders = append(gen.bufHeaders, ptr)
+			if uint(cap(buf)) >= size {
+				return buf[:0]
+			}
+		}
+		// Buffers which are too small are dropped.
+	}
+	return make([]byte, 0, size)
+}
+
+// SetBufferPool makes .PseudoRandomData() take the memory for its
+// results from the given pool where possible, instead of allocating
+// a new slice for every call.  This can reduce the load on the
+// garbage collector in programs which generate random data at high
+// rates.  Callers must hand buffers back using .ReturnBuffer() once
+// they are done with them; the pool must only be used with this
+// generator and must not contain anything except buffers returned
+// this way.  The pool stores values of type *[]byte, so that no
+// allocation is needed to put a buffer back.  If pool is nil, which is
+// the default, every call allocates a new slice.
+func (gen *Generator) SetBufferPool(pool *sync.Pool) {
+	gen.bufPool = pool
+}
+
+// ReturnBuffer overwrites buf with zeros and, if a buffer pool has
+// been set using .SetBufferPool(), puts buf into the pool for use by
+// later calls to .PseudoRandomData().  The caller must not retain or
+// use buf, or any slice sharing its memory, after ReturnBuffer has
+// been called: the memory will be handed out again, holding different
+// random data.
+func (gen *Generator) ReturnBuffer(buf []byte) {
+	buf = buf[:cap(buf)]
+	wipe(buf)
+	if gen.bufPool != nil {
+		var ptr *[]byte
+		if k := len(gen.bufHeaders); k > 0 {
+			ptr = gen.bufHeaders[k-1]
+			gen.bufHeaders = gen.bufHeaders[:k-1]
+		} else {
+			ptr = new([]byte)
+		}
+		*ptr = buf[:0]
+		gen.bufPool.Put(ptr)
+	}
+}
+
+// PeriodInfo describes the limits on how much output the generator
+// produces with a single key.  The return value blocksPerKey gives
+// the maximal number of cipher blocks generated using one key before
+// the generator rekeys; this is 2^16.  In counter mode, the blocks
+// generated with one key never repeat, whereas blocks of truly random
+// data would eventually collide.  Limiting the output per key to 2^16
+// blocks keeps this difference far below the birthday bound of the
+// block size, so that the absence of repeated blocks cannot be used
+// to distinguish the output from random data.  (The counter itself
+// is 128 bits long and could never wrap around in practice.)  The
+// value rekeysBeforeKeyReuse gives the number of rekeys after which,
+// by the birthday bound, a repeated key becomes likely: since every
+// new key is taken from the generator's output or derived via
+// SHA-256d, keys behave like random 256 bit strings, and a repetition
+// is expected only after about 2^128 rekeys.  Even at one billion
+// rekeys per second, this is more than 10^22 years.
+func (gen *Generator) PeriodInfo() (blocksPerKey uint64, rekeysBeforeKeyReuse float64) {
+	return maxBlocks, math.Ldexp(1, 8*keySize/2)
+}
+
+// BlockBytesGenerated returns the number of output bytes produced by
+// the block cipher since the generator was created.  Since output is
+// generated in whole cipher blocks, this can exceed the number of
+// bytes returned to callers, see .BytesReturned().  Blocks used for
+// rekeying are not included.
+func (gen *Generator) BlockBytesGenerated() uint64 {
+	return gen.blockBytes
+}
+
+// BytesReturned returns the number of random bytes returned to callers
+// since the generator was created; this corresponds to the
+// BytesGenerated field of AccumulatorStats.  The ratio
+// .BytesReturned()/.BlockBytesGenerated() shows how efficiently
+// generated output is used.  Since unused bytes of the final block are
+// discarded after every call (see .PseudoRandomData()), small requests
+// waste output; for example, .Int63() uses 8 bytes of a 16 byte AES
+// block, giving a ratio of 0.5.
+func (gen *Generator) BytesReturned() uint64 {
+	return gen.bytesReturned
+}
+
+// CounterValue returns a copy of the current value of the
+// generator's block counter.  The counter is stored least significant
+// byte first and has the length of one cipher block.  The counter is
+// incremented once for every block of output, and additionally for
+// the blocks used to generate each new key.  This is mostly useful
+// for testing and auditing.
+func (gen *Generator) CounterValue() []byte {
+	return append([]byte(nil), gen.counter...)
+}
+
+// Read allows to extract randomness from the Generator using the
+// io.Reader interface.  Read fills the byte slice p with
+// pseudo-random bytes.  If the generator is not seeded (see
+// .SetAutoSeed()), ErrNotSeeded is returned.  Otherwise the method
+// always reads len(p) bytes and returns a nil error.
+//
+// Code which needs to look ahead in the random stream can wrap the
+// generator in a bufio.Reader: bufio.NewReader(gen) provides Peek()
+// and Discard(), and bytes seen via Peek() are returned by the next
+// Read().  Note that buffered bytes stay in memory until they are
+// consumed, so that they lose the protection of the generator's
+// rekeying.
+func (gen *Generator) Read(p []byte) (n int, err error) {
+	if err := gen.checkSeeded(); err != nil {
+		return 0, err
+	}
+	copy(p, gen.PseudoRandomData(uint(len(p))))
+	return len(p), nil
+}
+
+// Close overwrites the key and counter of the generator with zeros
+// and marks the generator as unusable.  Closing is terminal: after
+// Close has been called, .Read() returns ErrClosed and
+// .PseudoRandomData() panics, even if the generator is seeded again.
+// If the key memory was locked using .LockMemory(), it is unlocked
+// and any error from unlocking is returned; otherwise Close always
+// returns nil.
+func (gen *Generator) Close() error {
+	gen.zeroize(gen.key)
+	gen.zeroize(gen.counter)
+	gen.cipher = nil
+	gen.closed = true
+
+	var err error
+	if gen.lockedMem != nil {
+		err = munlock(gen.lockedMem)
+		gen.lockedMem = nil
+	}
+	return err
+}
+
+// LockMemory locks the memory holding the generator's key into RAM,
+// so that the key cannot be written to swap space.  The key is moved
+// into a separate, page-sized buffer for this purpose and stays
+// there until the generator is closed; the memory is unlocked by the
+// .Close() method.
+//
+// Memory locking is only available on Linux and Mac OS X; on other
+// systems an error is returned.  Locking can also fail if the process
+// exceeds its limit for locked memory.  Only the key buffer is
+// locked: copies of key material made by the block cipher, for
+// example AES round keys, are not protected.
+func (gen *Generator) LockMemory() error {
+	if gen.lockedMem != nil {
+		return nil
+	}
+	if gen.closed {
+		return ErrClosed
+	}
+
+	buf := make([]byte, os.Getpagesize())
+	err := mlock(buf)
+	if err != nil {
+		return err
+	}
+	copy(buf, gen.key)
+	wipe(gen.key)
+	gen.key = buf[:keySize]
+	gen.lockedMem = buf
+	return nil
+}
+
 // Int63 returns a positive random integer, uniformly distributed on
 // the range 0, 1, ..., 2^63-1.  This function is part of the
 // rand.Source interface.
@@ -272,7 +707,54 @@ func (gen *Generator) Int63() int64 {
 //
 // Use of this method should be avoided in cryptographic applications,
 // since reproducible output will lead to security vulnerabilities.
+//
+// After .GoLive() has been called, Seed panics with ErrLive.
+//
+// Seed(0) is valid and yields a fixed, well-known output stream.
+// Since 0 is also the value of an uninitialised int64, this often
+// indicates a bug; if strict seeding has been enabled using
+// .SetStrictSeed(), Seed(0) panics with ErrZeroSeed.
 func (gen *Generator) Seed(seed int64) {
+	if gen.live {
+		panic(ErrLive)
+	}
+	if seed == 0 && gen.strictSeed {
+		panic(ErrZeroSeed)
+	}
 	gen.reset()
 	gen.ReseedInt64(seed)
 }
+
+// GoLive switches the generator from reproducible to unpredictable
+// output, by reseeding it with 32 bytes from the crypto/rand package.
+// This allows to use a seeded generator for a deterministic setup
+// phase and to continue with the same generator afterwards.  The
+// transition is one-way: once GoLive has succeeded, .Seed() panics
+// with ErrLive, to prevent the generator from accidentally being made
+// reproducible again.  .Reseed() can still be used to add entropy.
+// If the generator has been closed, ErrClosed is returned; if no
+// system randomness can be read, the corresponding error is returned
+// and the generator is left unchanged.
+func (gen *Generator) GoLive() error {
+	if gen.closed {
+		return ErrClosed
+	}
+	seed := make([]byte, keySize)
+	defer wipe(seed)
+	_, err := io.ReadFull(rand.Reader, seed)
+	if err != nil {
+		return err
+	}
+	gen.Reseed(seed)
+	gen.live = true
+	return nil
+}
+
+// SetStrictSeed enables or disables strict seeding.  If strict is
+// true, calling .Seed() with a seed of 0 panics with ErrZeroSeed
+// instead of producing the fixed output stream for this seed.  This
+// helps to catch seeds which were never initialised.  Strict seeding
+// is disabled by default.
+func (gen *Generator) SetStrictSeed(strict bool) {
+	gen.strictSeed = strict
+}
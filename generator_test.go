@@ -17,10 +17,16 @@
 package fortuna
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/aes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"math"
 	"math/rand"
+	"sync"
 	"testing"
 )
 
@@ -30,6 +36,17 @@ func TestConstants(t *testing.T) {
 	}
 }
 
+func TestPeriodInfo(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	blocks, rekeys := gen.PeriodInfo()
+	if blocks != 1<<16 {
+		t.Errorf("wrong number of blocks per key: %d", blocks)
+	}
+	if rekeys != math.Pow(2, 128) {
+		t.Errorf("wrong number of rekeys: %g", rekeys)
+	}
+}
+
 func TestOutput(t *testing.T) {
 	// The reference values in this function are generated using the
 	// "Python Cryptography Toolkit",
@@ -101,6 +118,61 @@ func TestReseed(t *testing.T) {
 	}
 }
 
+// stateHash returns a hash of the complete state of the generator,
+// so that tests can check whether the state has changed.
+func (gen *Generator) stateHash() []byte {
+	hash := sha256.New()
+	hash.Write(gen.key)
+	hash.Write(gen.counter)
+	return hash.Sum(nil)
+}
+
+// bitDifference returns the fraction of bits which differ between a
+// and b.
+func bitDifference(a, b []byte) float64 {
+	diff := 0
+	for i := range a {
+		x := a[i] ^ b[i]
+		for ; x != 0; x &= x - 1 {
+			diff++
+		}
+	}
+	return float64(diff) / float64(8*len(a))
+}
+
+// assertReseedEffective checks that reseed modifies the state of gen
+// and that the subsequent output is unrelated to the output which gen
+// would have produced without the reseed.
+func assertReseedEffective(t *testing.T, name string, gen *Generator, reseed func()) {
+	t.Helper()
+	before := gen.stateHash()
+	unchanged := gen.clone()
+	reseed()
+	if bytes.Equal(gen.stateHash(), before) {
+		t.Errorf("%s did not change the generator state", name)
+	}
+	x := gen.PseudoRandomData(1024)
+	y := unchanged.PseudoRandomData(1024)
+	if d := bitDifference(x, y); d < 0.45 || d > 0.55 {
+		t.Errorf("%s: output correlated with old output (%.3f bits differ)",
+			name, d)
+	}
+}
+
+func TestReseedChangesState(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+	assertReseedEffective(t, "Reseed", gen, func() {
+		gen.Reseed([]byte{1})
+	})
+	assertReseedEffective(t, "Reseed(nil)", gen, func() {
+		gen.Reseed(nil)
+	})
+	assertReseedEffective(t, "ReseedInt64", gen, func() {
+		gen.ReseedInt64(0)
+	})
+}
+
 func TestSeed(t *testing.T) {
 	rng := NewGenerator(aes.NewCipher)
 
@@ -115,6 +187,689 @@ func TestSeed(t *testing.T) {
 	}
 }
 
+func TestStrictSeed(t *testing.T) {
+	rng := NewGenerator(aes.NewCipher)
+	rng.SetStrictSeed(true)
+	rng.Seed(1)
+	expected := rng.PseudoRandomData(16)
+
+	func() {
+		defer func() {
+			if r := recover(); r != ErrZeroSeed {
+				t.Errorf("wrong panic value %v", r)
+			}
+		}()
+		rng.Seed(0)
+	}()
+	// A refused seed must leave the state alone.
+	rng.Seed(1)
+	if !bytes.Equal(rng.PseudoRandomData(16), expected) {
+		t.Error("wrong output after refused seed")
+	}
+
+	rng.SetStrictSeed(false)
+	rng.Seed(0)
+	x := rng.PseudoRandomData(16)
+	rng.Seed(0)
+	if !bytes.Equal(rng.PseudoRandomData(16), x) || isZero(x) {
+		t.Error("Seed(0) not usable without strict seeding")
+	}
+}
+
+func TestGoLive(t *testing.T) {
+	rng1 := NewGenerator(aes.NewCipher)
+	rng1.Seed(1)
+	rng2 := NewGenerator(aes.NewCipher)
+	rng2.Seed(1)
+	if !bytes.Equal(rng1.PseudoRandomData(16), rng2.PseudoRandomData(16)) {
+		t.Fatal("seeded generators differ")
+	}
+
+	if err := rng1.GoLive(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rng2.GoLive(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(rng1.PseudoRandomData(32), rng2.PseudoRandomData(32)) {
+		t.Error("output still reproducible after GoLive()")
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != ErrLive {
+				t.Errorf("wrong panic value %v", r)
+			}
+		}()
+		rng1.Seed(1)
+	}()
+
+	rng1.Close()
+	if err := rng1.GoLive(); err != ErrClosed {
+		t.Errorf("GoLive() on closed generator: %v", err)
+	}
+}
+
+func TestNoResidual(t *testing.T) {
+	// Both generators go through the same sequence of keys and
+	// counter values, but rng1 uses only one byte of the first block.
+	rng1 := NewGenerator(aes.NewCipher)
+	rng1.Seed(1)
+	rng2 := NewGenerator(aes.NewCipher)
+	rng2.Seed(1)
+
+	rng1.PseudoRandomData(1)
+	block := rng2.PseudoRandomData(16)
+
+	rng1.Reseed([]byte{1, 2, 3})
+	rng2.Reseed([]byte{1, 2, 3})
+	x := rng1.PseudoRandomData(15)
+	y := rng2.PseudoRandomData(15)
+	if bytes.Compare(x, y) != 0 {
+		t.Error("left-over bytes influenced output after reseeding")
+	}
+	if bytes.Compare(x, block[1:]) == 0 {
+		t.Error("left-over bytes were returned after reseeding")
+	}
+}
+
+func TestForwardSecure(t *testing.T) {
+	rng := NewGenerator(aes.NewCipher)
+	rng.Seed(1)
+
+	oldKey := append([]byte(nil), rng.key...)
+	secret := rng.PseudoRandomData(32)
+	if bytes.Equal(rng.key, oldKey) {
+		t.Fatal("key not replaced after PseudoRandomData()")
+	}
+
+	// Someone who learns the state right after the call can only
+	// reproduce later output, not the secret.
+	copy1 := rng.clone()
+	later := copy1.PseudoRandomData(1 << 16)
+	if bytes.Contains(later, secret) {
+		t.Error("secret can be reproduced from the post-call state")
+	}
+	copy2 := rng.clone()
+	copy2.counter = make([]byte, len(rng.counter))
+	copy2.counter[0] = 1
+	if bytes.Contains(copy2.PseudoRandomData(1<<16), secret[:16]) {
+		t.Error("secret can be reproduced by rewinding the counter")
+	}
+}
+
+func TestForwardSecureRekey(t *testing.T) {
+	rng := NewGenerator(aes.NewCipher)
+	rng.Seed(1)
+
+	startKey := append([]byte(nil), rng.key...)
+	startCounter := rng.CounterValue()
+	// One block more than maxBlocks forces a rekey in the middle of
+	// the request, before the final block is generated.
+	n := uint(maxBlocks*aes.BlockSize + aes.BlockSize)
+	data := rng.PseudoRandomData(n)
+	first := data[:aes.BlockSize]
+	last := data[maxBlocks*aes.BlockSize:]
+
+	// The state after the call stands in for a serialized state
+	// captured by an attacker.
+	captured := rng.clone()
+	if bytes.Equal(captured.key, startKey) {
+		t.Fatal("key not replaced")
+	}
+
+	// Even with the counter rewound to the start of the request,
+	// the captured key does not reproduce the output.
+	captured.counter = append([]byte(nil), startCounter...)
+	replay := captured.generateBlocks(nil, maxBlocks+4)
+	if bytes.Contains(replay, first) || bytes.Contains(replay, last) {
+		t.Error("pre-rekey output reproduced from post-rekey state")
+	}
+
+	// In contrast, the old key does reproduce the first part.
+	old := &Generator{newCipher: aes.NewCipher, counter: startCounter}
+	old.setKey(startKey)
+	if !bytes.Equal(old.generateBlocks(nil, 1), first) {
+		t.Error("test setup broken: old key does not reproduce output")
+	}
+}
+
+func TestCombine(t *testing.T) {
+	a := NewGenerator(aes.NewCipher)
+	a.Seed(1)
+	b := NewGenerator(aes.NewCipher)
+	b.Seed(2)
+	aOut := a.PseudoRandomData(16)
+	a.Seed(1)
+
+	c1, err := Combine(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := Combine(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := c1.PseudoRandomData(100)
+	y := c2.PseudoRandomData(100)
+	if bytes.Compare(x, y) != 0 {
+		t.Error("Combine() is not reproducible")
+	}
+
+	c3, err := Combine(b, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := c3.PseudoRandomData(100)
+	if bytes.Compare(x, z) == 0 {
+		t.Error("Combine() ignores the order of the arguments")
+	}
+
+	if bytes.Compare(a.PseudoRandomData(16), aOut) != 0 {
+		t.Error("Combine() modified its arguments")
+	}
+}
+
+// streamsOverlap draws n bytes from clones of a and b and reports
+// whether any block-sized substring of the output of a also occurs
+// in the output of b.  The generators a and b are not modified.
+func streamsOverlap(a, b *Generator, n uint) bool {
+	k := len(a.counter)
+	x := a.clone().PseudoRandomData(n)
+	y := b.clone().PseudoRandomData(n)
+
+	seen := make(map[string]bool)
+	for i := 0; i+k <= len(y); i++ {
+		seen[string(y[i:i+k])] = true
+	}
+	for i := 0; i+k <= len(x); i++ {
+		if seen[string(x[i:i+k])] {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStreamsOverlap(t *testing.T) {
+	master := NewGenerator(aes.NewCipher)
+	master.Seed(1)
+
+	if !streamsOverlap(master, master, 1000) {
+		t.Error("failed to detect identical streams")
+	}
+	shifted := master.clone()
+	shifted.generateBlocks(nil, 3)
+	if !streamsOverlap(master, shifted, 1000) {
+		t.Error("failed to detect shifted streams")
+	}
+
+	var siblings []*Generator
+	for i := 0; i < 4; i++ {
+		sibling := master.clone()
+		sibling.Reseed([]byte{byte(i)})
+		siblings = append(siblings, sibling)
+	}
+	for i := range siblings {
+		if streamsOverlap(master, siblings[i], 1<<16) {
+			t.Errorf("sibling %d overlaps with the master", i)
+		}
+		for j := i + 1; j < len(siblings); j++ {
+			if streamsOverlap(siblings[i], siblings[j], 1<<16) {
+				t.Errorf("siblings %d and %d overlap", i, j)
+			}
+		}
+	}
+}
+
+// outputFingerprint returns the hex-encoded SHA-256 hash of n bytes
+// of output, drawn from a clone of gen.  The generator gen is not
+// modified.
+func outputFingerprint(gen *Generator, n uint) string {
+	hash := sha256.Sum256(gen.clone().PseudoRandomData(n))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestFingerprints(t *testing.T) {
+	// These values guard against accidental changes of the output.
+	// If any of them change, the generated data is no longer
+	// compatible with earlier versions of this package.
+	cases := []struct {
+		seed        int64
+		n           uint
+		fingerprint string
+	}{
+		{0, 1000,
+			"93d78d8165ccb87b2da15ea292f8f058ec144096a52d1ff71f7d66166ea7f600"},
+		{1, 1000,
+			"a3f218b14ff40902801867f9f2712c270eeb56d86b818499933fe1c6aa65ec8e"},
+		{1 << 62, 1000,
+			"53a5be6425bcabe02315389a189b46899290ada19181cc3fff381329ebe8cd04"},
+		{-1, 1 << 21,
+			"7ee0d6c6c89bf81b5aadb0028b6ea6af19a9c551c23e6f1fd57f318a8d4b3311"},
+	}
+
+	gen := NewGenerator(aes.NewCipher)
+	for _, c := range cases {
+		gen.Seed(c.seed)
+		fingerprint := outputFingerprint(gen, c.n)
+		if fingerprint != c.fingerprint {
+			t.Errorf("seed %d: wrong fingerprint %s", c.seed, fingerprint)
+		}
+		if outputFingerprint(gen, c.n) != fingerprint {
+			t.Errorf("seed %d: outputFingerprint modified the generator", c.seed)
+		}
+	}
+}
+
+func TestNotSeeded(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.reset()
+
+	hasPanicked := func() (hasPanicked bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				hasPanicked = true
+			}
+		}()
+		gen.PseudoRandomData(1)
+		return false
+	}()
+	if !hasPanicked {
+		t.Error("failed to detect unseeded generator")
+	}
+
+	buf := make([]byte, 16)
+	n, err := gen.Read(buf)
+	if n != 0 || err != ErrNotSeeded {
+		t.Errorf("wrong result %d, %v for unseeded generator", n, err)
+	}
+
+	gen.SetAutoSeed(true)
+	n, err = gen.Read(buf)
+	if n != 16 || err != nil {
+		t.Errorf("auto-seeding failed: %d, %v", n, err)
+	}
+	gen.reset()
+	out := gen.PseudoRandomData(16)
+	gen.reset()
+	gen.Reseed(nil)
+	if bytes.Compare(out, gen.PseudoRandomData(16)) == 0 {
+		t.Error("generator was not seeded randomly")
+	}
+}
+
+func TestBufferedPeek(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+	ref := NewGenerator(aes.NewCipher)
+	ref.Seed(1)
+	// The reader fills its 4096 byte buffer with a single call to
+	// gen.Read(), so all data read below comes from one call.
+	expected := make([]byte, 4096)
+	ref.Read(expected)
+
+	r := bufio.NewReaderSize(gen, 4096)
+	peeked, err := r.Peek(10)
+	if err != nil || !bytes.Equal(peeked, expected[:10]) {
+		t.Fatalf("Peek() returned wrong data: %v", err)
+	}
+	peeked = append([]byte(nil), peeked...)
+	buf := make([]byte, 20)
+	_, err = io.ReadFull(r, buf)
+	if err != nil || !bytes.Equal(buf[:10], peeked) {
+		t.Fatal("peeked bytes not returned by Read()")
+	}
+	if !bytes.Equal(buf, expected[:20]) {
+		t.Fatal("wrong data after Peek()")
+	}
+
+	n, err := r.Discard(4000)
+	if n != 4000 || err != nil {
+		t.Fatalf("Discard() failed: %d %v", n, err)
+	}
+	_, err = io.ReadFull(r, buf)
+	if err != nil || !bytes.Equal(buf, expected[4020:4040]) {
+		t.Error("Discard() did not advance the stream correctly")
+	}
+}
+
+func TestBufferPool(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+	ref := NewGenerator(aes.NewCipher)
+	ref.Seed(1)
+	pool := &sync.Pool{}
+	gen.SetBufferPool(pool)
+
+	buf := gen.PseudoRandomData(100)
+	if !bytes.Equal(buf, ref.PseudoRandomData(100)) {
+		t.Fatal("buffer pool changed the output")
+	}
+	mem := buf[:cap(buf)]
+	gen.ReturnBuffer(buf)
+	if !isZero(mem) {
+		t.Fatal("returned buffer not zeroed")
+	}
+
+	// The pool may drop the buffer at any time, so the output can
+	// only be checked, not the reuse itself.
+	buf = gen.PseudoRandomData(50)
+	if !bytes.Equal(buf, ref.PseudoRandomData(50)) {
+		t.Error("wrong output from recycled buffer")
+	}
+	if &buf[0] == &mem[0] && !isZero(mem[len(buf):]) {
+		t.Error("recycled buffer contains old data")
+	}
+
+	gen.ReturnBuffer(buf)
+	if x := pool.Get(); x != nil {
+		if _, ok := x.(*[]byte); !ok {
+			t.Errorf("pool contains a %T, not a *[]byte", x)
+		}
+	}
+}
+
+func TestBytesReturned(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+	gen0, con0 := gen.BlockBytesGenerated(), gen.BytesReturned()
+
+	for i := 0; i < 1000; i++ {
+		gen.Int63()
+	}
+	generated := gen.BlockBytesGenerated() - gen0
+	consumed := gen.BytesReturned() - con0
+	if consumed != 8000 || generated != 16000 {
+		t.Errorf("Int63: %d bytes returned, %d generated", consumed, generated)
+	}
+
+	for i := 0; i < 1000; i++ {
+		gen.Roll(6)
+	}
+	generated = gen.BlockBytesGenerated() - gen0 - generated
+	consumed = gen.BytesReturned() - con0 - consumed
+	ratio := float64(consumed) / float64(generated)
+	if ratio != 0.5 {
+		t.Errorf("Roll: wrong ratio %g", ratio)
+	}
+
+	before := gen.BlockBytesGenerated()
+	gen.PseudoRandomData(1 << 20)
+	if gen.BlockBytesGenerated()-before != 1<<20 {
+		t.Error("wrong count for large request")
+	}
+}
+
+func TestBlockPostProcess(t *testing.T) {
+	ref := NewGenerator(aes.NewCipher)
+	ref.Seed(1)
+	identity := NewGenerator(aes.NewCipher)
+	identity.Seed(1)
+	identity.SetBlockPostProcess(func(block []byte) {})
+	reversed := NewGenerator(aes.NewCipher)
+	reversed.Seed(1)
+	reversed.SetBlockPostProcess(func(block []byte) {
+		for i, j := 0, len(block)-1; i < j; i, j = i+1, j-1 {
+			block[i], block[j] = block[j], block[i]
+		}
+	})
+
+	for _, n := range []uint{32, 40, 16} {
+		expected := ref.clone().generateBlocks(nil, ref.numBlocks(n))
+		ref.PseudoRandomData(n)
+		if !bytes.Equal(identity.PseudoRandomData(n), expected[:n]) {
+			t.Errorf("identity post-processing changed the output")
+		}
+		x := reversed.PseudoRandomData(n)
+		for i := uint(0); i < n; i++ {
+			block, pos := i/16, i%16
+			if x[i] != expected[16*block+15-pos] {
+				t.Fatalf("%d: block %d not reversed", n, block)
+			}
+		}
+		// The state must not be affected by the post-processing.
+		if !bytes.Equal(reversed.key, ref.key) {
+			t.Fatal("post-processing changed the key")
+		}
+	}
+}
+
+func TestGeneratorClose(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	key := gen.key
+	counter := gen.counter
+
+	err := gen.Close()
+	if err != nil {
+		t.Error(err)
+	}
+	if !isZero(key) || !isZero(counter) {
+		t.Error("key material not zeroed")
+	}
+
+	buf := make([]byte, 16)
+	n, err := gen.Read(buf)
+	if n != 0 || err != ErrClosed {
+		t.Errorf("wrong result %d, %v after Close", n, err)
+	}
+	gen.Seed(1)
+	n, err = gen.Read(buf)
+	if n != 0 || err != ErrClosed {
+		t.Errorf("wrong result %d, %v after Close and Seed", n, err)
+	}
+}
+
+func TestLockMemory(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+	x := gen.PseudoRandomData(100)
+
+	gen.Seed(1)
+	err := gen.LockMemory()
+	if err != nil {
+		t.Skip("memory locking not available:", err)
+	}
+	key := gen.key
+	y := gen.PseudoRandomData(100)
+	if bytes.Compare(x, y) != 0 {
+		t.Error("LockMemory changed the generator output")
+	}
+	if &gen.key[0] != &key[0] {
+		t.Error("key moved out of the locked buffer")
+	}
+
+	err = gen.Close()
+	if err != nil {
+		t.Error("unlocking failed:", err)
+	}
+	if !isZero(key) {
+		t.Error("locked key not zeroed")
+	}
+}
+
+func TestZeroizePasses(t *testing.T) {
+	saved := zeroizeFill
+	defer func() { zeroizeFill = saved }()
+	fills := 0
+	zeroizeFill = func(data []byte) {
+		fills++
+		saved(data)
+	}
+
+	for _, passes := range []int{0, 1, 2, 5} {
+		rng := NewGenerator(aes.NewCipher)
+		rng.SetZeroizePasses(passes)
+		key := rng.key
+
+		fills = 0
+		rng.Close()
+		expected := 0
+		if passes > 1 {
+			// key and counter are both overwritten
+			expected = 2 * (passes - 1)
+		}
+		if fills != expected {
+			t.Errorf("%d passes: %d random fills on Close(), expected %d",
+				passes, fills, expected)
+		}
+		if !isZero(key) {
+			t.Errorf("%d passes: key not zero after Close()", passes)
+		}
+	}
+
+	rng := NewGenerator(aes.NewCipher)
+	rng.SetZeroizePasses(3)
+	fills = 0
+	rng.Reseed([]byte{1, 2, 3})
+	// old key and temporary new key, two random passes each
+	if fills != 4 {
+		t.Errorf("%d random fills on Reseed(), expected 4", fills)
+	}
+}
+
+func TestCounterValue(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+
+	expected := make([]byte, 16)
+	expected[0] = 1
+	counter := gen.CounterValue()
+	if bytes.Compare(counter, expected) != 0 {
+		t.Errorf("wrong counter after seeding: %v", counter)
+	}
+	counter[0] = 99
+	if gen.counter[0] != 1 {
+		t.Error("CounterValue() returned internal state")
+	}
+
+	// 100 bytes need 7 blocks, plus 2 blocks for the new key
+	gen.PseudoRandomData(100)
+	expected[0] = 1 + 7 + 2
+	if bytes.Compare(gen.CounterValue(), expected) != 0 {
+		t.Errorf("wrong counter after 100 bytes: %v", gen.CounterValue())
+	}
+
+	// 2^16+1 blocks require two new keys
+	gen.PseudoRandomData((maxBlocks + 1) * 16)
+	expected[0] += 1 + 2*2
+	expected[2] = 1
+	if bytes.Compare(gen.CounterValue(), expected) != 0 {
+		t.Errorf("wrong counter after 2^16+1 blocks: %v", gen.CounterValue())
+	}
+}
+
+func TestPseudoRandomDataTagged(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+	ref := gen.clone().PseudoRandomData(32)
+
+	a1 := gen.PseudoRandomDataTagged([]byte("a"), 32)
+	b := gen.PseudoRandomDataTagged([]byte("b"), 32)
+	a2 := gen.PseudoRandomDataTagged([]byte("a"), 32)
+	if bytes.Compare(a1, a2) != 0 {
+		t.Error("tagged output not reproducible")
+	}
+	if bytes.Compare(a1, b) == 0 || bytes.Compare(a1, ref) == 0 ||
+		bytes.Compare(b, ref) == 0 {
+		t.Error("tagged streams not independent")
+	}
+	if bytes.Compare(gen.PseudoRandomData(32), ref) != 0 {
+		t.Error("tagged output modified the generator state")
+	}
+
+	gen.Seed(1)
+	gen.PseudoRandomData(1)
+	if bytes.Compare(a1, gen.PseudoRandomDataTagged([]byte("a"), 32)) == 0 {
+		t.Error("tagged output does not depend on the generator state")
+	}
+}
+
+// findWeakPattern checks data for patterns which are extremely
+// unlikely to occur in random output, but which typical
+// implementation errors, for example a stuck counter or a broken
+// cipher, would produce: long runs of identical bytes, repeated
+// blocks, and blocks consisting only of zero bits or only of one
+// bits.  The data is split into blocks of 16 bytes, the AES block
+// size.  If a weak pattern is found, a description is returned;
+// otherwise the result is the empty string.
+func findWeakPattern(data []byte) string {
+	// For 2^30 random bytes, the probability of seeing a run of 8 or
+	// more identical bytes is approximately 2^30 * 256^-7 = 2^-26.
+	run := 1
+	for i := 1; i < len(data); i++ {
+		if data[i] == data[i-1] {
+			run++
+			if run >= 8 {
+				return fmt.Sprintf("run of %d bytes 0x%02x at offset %d",
+					run, data[i], i-run+1)
+			}
+		} else {
+			run = 1
+		}
+	}
+
+	const blockSize = 16
+	zero := make([]byte, blockSize)
+	ones := bytes.Repeat([]byte{0xff}, blockSize)
+	seen := make(map[string]int)
+	for i := 0; i+blockSize <= len(data); i += blockSize {
+		block := data[i : i+blockSize]
+		if bytes.Compare(block, zero) == 0 || bytes.Compare(block, ones) == 0 {
+			return fmt.Sprintf("degenerate block %x at offset %d", block, i)
+		}
+		if j, ok := seen[string(block)]; ok {
+			return fmt.Sprintf("block at offset %d repeats block at offset %d", i, j)
+		}
+		seen[string(block)] = i
+	}
+	return ""
+}
+
+// assertNoWeakPatterns reports a test failure if findWeakPattern()
+// finds a weak pattern in data.
+func assertNoWeakPatterns(t *testing.T, data []byte) {
+	t.Helper()
+	if msg := findWeakPattern(data); msg != "" {
+		t.Error(msg)
+	}
+}
+
+func TestNoWeakPatterns(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+
+	// 4 MiB of output crosses several rekeying boundaries.
+	assertNoWeakPatterns(t, gen.PseudoRandomData(4<<20))
+
+	// many small requests, each with its own rekey
+	var data []byte
+	for i := 0; i < 1<<14; i++ {
+		data = append(data, gen.PseudoRandomData(16)...)
+	}
+	assertNoWeakPatterns(t, data)
+}
+
+func TestFindWeakPattern(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+	data := gen.PseudoRandomData(1024)
+	if msg := findWeakPattern(data); msg != "" {
+		t.Fatal(msg)
+	}
+
+	for i, corrupt := range []func(data []byte){
+		func(data []byte) { copy(data[100:110], make([]byte, 10)) },
+		func(data []byte) { copy(data[512:528], data[32:48]) },
+		func(data []byte) { copy(data[512:528], bytes.Repeat([]byte{0xff}, 16)) },
+	} {
+		tmp := append([]byte(nil), data...)
+		corrupt(tmp)
+		if findWeakPattern(tmp) == "" {
+			t.Errorf("weak pattern %d not detected", i)
+		}
+	}
+}
+
 func TestPrng(t *testing.T) {
 	rng := NewGenerator(aes.NewCipher)
 	rng.Seed(123)
@@ -165,9 +920,29 @@ func generator(b *testing.B, n uint) {
 	}
 }
 
+func generatorPooled(b *testing.B, pool *sync.Pool) {
+	rng := NewGenerator(aes.NewCipher)
+	rng.Seed(0)
+	rng.SetBufferPool(pool)
+
+	b.SetBytes(4096)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := rng.PseudoRandomData(4096)
+		rng.ReturnBuffer(buf)
+	}
+}
+
+func BenchmarkGeneratorNoPool(b *testing.B) { generatorPooled(b, nil) }
+func BenchmarkGeneratorPool(b *testing.B)   { generatorPooled(b, &sync.Pool{}) }
+
 func BenchmarkGenerator16(b *testing.B) { generator(b, 16) }
 func BenchmarkGenerator32(b *testing.B) { generator(b, 32) }
 func BenchmarkGenerator1k(b *testing.B) { generator(b, 1024) }
 
 // compile-time test: Generator implements the rand.Source interface
 var _ rand.Source = &Generator{}
+
+// compile-time test: Generator implements the io.ReadCloser interface
+var _ io.ReadCloser = &Generator{}
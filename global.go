@@ -0,0 +1,167 @@
+// global.go - a drop-in replacement for the top-level math/rand functions
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/aes"
+	"math/rand"
+	"sync"
+)
+
+// The functions in this file mirror the top-level functions of the
+// math/rand package, so that code can switch from math/rand to this
+// package by changing the import.  All functions use one shared
+// Generator, which is created on first use and seeded from the
+// system, including 32 bytes from crypto/rand (see
+// Generator.GoLive()).  Access to the shared generator is serialised
+// by a mutex, so the functions are safe for concurrent use, but heavy
+// concurrent use may be limited by lock contention; such programs
+// should use separate generators per goroutine.  There is no
+// top-level Seed function: the output is never reproducible.
+
+var (
+	globalOnce  sync.Once
+	globalMutex sync.Mutex
+	globalGen   *Generator
+	globalRand  *rand.Rand
+	globalErr   error
+
+	// globalGoLive seeds the shared generator; tests replace this to
+	// simulate a failure of the system entropy source.
+	globalGoLive = (*Generator).GoLive
+)
+
+// withGlobal calls fn with the shared generator, and the math/rand
+// wrapper around it, while holding the global lock.  If the shared
+// generator could not be seeded, withGlobal panics with the seeding
+// error, on the first and on all subsequent calls.
+func withGlobal(fn func(gen *Generator, r *rand.Rand)) {
+	globalOnce.Do(func() {
+		gen := NewGenerator(aes.NewCipher)
+		err := globalGoLive(gen)
+		if err != nil {
+			globalErr = err
+			return
+		}
+		globalGen = gen
+		globalRand = rand.New(gen)
+	})
+	if globalErr != nil {
+		panic(globalErr)
+	}
+	globalMutex.Lock()
+	defer globalMutex.Unlock()
+	fn(globalGen, globalRand)
+}
+
+// Int63 returns a non-negative random 63-bit integer as an int64.
+func Int63() (x int64) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.Int63() })
+	return
+}
+
+// Uint32 returns a random 32-bit value as a uint32.
+func Uint32() (x uint32) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.Uint32() })
+	return
+}
+
+// Uint64 returns a random 64-bit value as a uint64.
+func Uint64() (x uint64) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.Uint64() })
+	return
+}
+
+// Int31 returns a non-negative random 31-bit integer as an int32.
+func Int31() (x int32) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.Int31() })
+	return
+}
+
+// Int returns a non-negative random int.
+func Int() (x int) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.Int() })
+	return
+}
+
+// Int63n returns a random int64 in the range 0, 1, ..., n-1.  It
+// panics if n <= 0.
+func Int63n(n int64) (x int64) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.Int63n(n) })
+	return
+}
+
+// Int31n returns a random int32 in the range 0, 1, ..., n-1.  It
+// panics if n <= 0.
+func Int31n(n int32) (x int32) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.Int31n(n) })
+	return
+}
+
+// Intn returns a random int in the range 0, 1, ..., n-1.  It panics
+// if n <= 0.
+func Intn(n int) (x int) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.Intn(n) })
+	return
+}
+
+// Float64 returns a random float64 in the interval [0.0, 1.0).
+func Float64() (x float64) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.Float64() })
+	return
+}
+
+// Float32 returns a random float32 in the interval [0.0, 1.0).
+func Float32() (x float32) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.Float32() })
+	return
+}
+
+// NormFloat64 returns a normally distributed float64 with mean 0 and
+// standard deviation 1.
+func NormFloat64() (x float64) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.NormFloat64() })
+	return
+}
+
+// ExpFloat64 returns an exponentially distributed float64 with rate
+// parameter 1.
+func ExpFloat64() (x float64) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { x = r.ExpFloat64() })
+	return
+}
+
+// Perm returns a random permutation of the integers 0, 1, ..., n-1.
+func Perm(n int) (p []int) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { p = r.Perm(n) })
+	return
+}
+
+// Shuffle randomly permutes n elements, using swap to exchange the
+// elements with indices i and j.  It panics if n < 0.  The function
+// swap is called while the global lock is held, so it must not call
+// other functions from this file.
+func Shuffle(n int, swap func(i, j int)) {
+	withGlobal(func(_ *Generator, r *rand.Rand) { r.Shuffle(n, swap) })
+}
+
+// Read fills p with random bytes.  It always returns len(p) and a nil
+// error.
+func Read(p []byte) (n int, err error) {
+	withGlobal(func(gen *Generator, _ *rand.Rand) { n, err = gen.Read(p) })
+	return
+}
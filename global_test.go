@@ -0,0 +1,139 @@
+// global_test.go - unit tests for global.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestGlobalFunctions(t *testing.T) {
+	if x := Int63(); x < 0 {
+		t.Errorf("Int63() = %d", x)
+	}
+	if x := Int31(); x < 0 {
+		t.Errorf("Int31() = %d", x)
+	}
+	if x := Int(); x < 0 {
+		t.Errorf("Int() = %d", x)
+	}
+	if Uint32() == 0 && Uint32() == 0 && Uint32() == 0 {
+		t.Error("Uint32() returns zero")
+	}
+	if Uint64() == 0 && Uint64() == 0 {
+		t.Error("Uint64() returns zero")
+	}
+	for i := 0; i < 100; i++ {
+		if x := Intn(7); x < 0 || x >= 7 {
+			t.Fatalf("Intn(7) = %d", x)
+		}
+		if x := Int31n(7); x < 0 || x >= 7 {
+			t.Fatalf("Int31n(7) = %d", x)
+		}
+		if x := Int63n(1 << 40); x < 0 || x >= 1<<40 {
+			t.Fatalf("Int63n(1<<40) = %d", x)
+		}
+		if x := Float64(); x < 0 || x >= 1 {
+			t.Fatalf("Float64() = %g", x)
+		}
+		if x := Float32(); x < 0 || x >= 1 {
+			t.Fatalf("Float32() = %g", x)
+		}
+		if x := ExpFloat64(); x < 0 {
+			t.Fatalf("ExpFloat64() = %g", x)
+		}
+	}
+	NormFloat64()
+
+	p := Perm(20)
+	sort.Ints(p)
+	for i, x := range p {
+		if x != i {
+			t.Fatalf("Perm() is not a permutation: %v", p)
+		}
+	}
+
+	a := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	Shuffle(len(a), func(i, j int) { a[i], a[j] = a[j], a[i] })
+	sort.Ints(a)
+	for i, x := range a {
+		if x != i {
+			t.Fatalf("Shuffle() lost elements: %v", a)
+		}
+	}
+
+	buf := make([]byte, 100)
+	n, err := Read(buf)
+	if n != 100 || err != nil || isZero(buf) {
+		t.Errorf("Read() = %d, %v", n, err)
+	}
+
+	if !globalGen.live {
+		t.Error("global generator not seeded from the system")
+	}
+}
+
+// This test is most useful when run with the -race flag.
+func TestGlobalConcurrent(t *testing.T) {
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 16)
+			for j := 0; j < 200; j++ {
+				Intn(10)
+				Float64()
+				Read(buf)
+				Perm(5)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGlobalSeedFailure(t *testing.T) {
+	globalMutex.Lock()
+	oldGen, oldRand := globalGen, globalRand
+	globalMutex.Unlock()
+	defer func() {
+		globalGoLive = (*Generator).GoLive
+		globalErr = nil
+		globalGen, globalRand = oldGen, oldRand
+		if oldGen == nil {
+			globalOnce = sync.Once{}
+		}
+	}()
+
+	errSeed := errors.New("no entropy")
+	globalOnce = sync.Once{}
+	globalGen, globalRand = nil, nil
+	globalGoLive = func(*Generator) error { return errSeed }
+
+	for i := 0; i < 3; i++ {
+		func() {
+			defer func() {
+				if r := recover(); r != errSeed {
+					t.Errorf("call %d: recovered %v, expected %v", i, r, errSeed)
+				}
+			}()
+			Intn(10)
+		}()
+	}
+}
@@ -0,0 +1,94 @@
+// handler.go - serve random data over HTTP
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// httpDefaultMaxLen is the largest request served by the handler
+// returned by NewHTTPHandler, if no limit has been set using
+// Accumulator.SetMaxReadSize().
+const httpDefaultMaxLen = 1 << 16
+
+// NewHTTPHandler returns an http.Handler which serves random bytes
+// from the Accumulator acc.  The number of bytes is given by the
+// "len" query parameter, for example "/random?len=32".  The response
+// has content type "application/octet-stream".  Missing, malformed
+// or negative lengths result in a "400 Bad Request" response.
+//
+// Requests are limited to the size set with acc.SetMaxReadSize(), or
+// to 65536 bytes if no limit has been set.  Requests exceeding the
+// limit are either truncated, or answered with "413 Request Entity
+// Too Large", depending on acc.SetTruncateReads().  The limit is
+// checked before any memory is allocated for the response.
+//
+// Random data sent over the network is only as secret as the
+// connection it travels on: anybody who can observe the traffic
+// learns the bytes, and anybody who can modify it can substitute
+// values of their choice.  The handler must therefore only be served
+// over an authenticated and encrypted channel, for example using TLS
+// with client certificates, and clients should mix the received data
+// into a local generator (e.g. using Generator.Reseed()) rather than
+// using it directly as key material.
+//
+// The same pattern can be used for gRPC or other RPC frameworks:
+// parse the requested length, check it against a limit, read the
+// data using acc.Read() into a buffer of that size, and map
+// ErrReadTooLarge to a suitable error status such as
+// codes.ResourceExhausted.
+func NewHTTPHandler(acc *Accumulator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n, err := strconv.Atoi(req.URL.Query().Get("len"))
+		if err != nil || n < 0 {
+			http.Error(w, "invalid len parameter", http.StatusBadRequest)
+			return
+		}
+
+		acc.genMutex.Lock()
+		limit, truncate := acc.maxReadSize, acc.truncateReads
+		acc.genMutex.Unlock()
+		if limit <= 0 {
+			limit = httpDefaultMaxLen
+		}
+		if n > limit {
+			if !truncate {
+				http.Error(w, ErrReadTooLarge.Error(),
+					http.StatusRequestEntityTooLarge)
+				return
+			}
+			n = limit
+		}
+
+		buf := make([]byte, n)
+		n, err = acc.Read(buf)
+		if err == ErrReadTooLarge {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(n))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(buf[:n])
+		wipe(buf)
+	})
+}
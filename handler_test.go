@@ -0,0 +1,98 @@
+// handler_test.go - unit tests for handler.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	acc, _ := NewRNG("")
+	defer acc.Close()
+	acc.SetMaxReadSize(64)
+	handler := NewHTTPHandler(acc)
+
+	for _, test := range []struct {
+		query  string
+		status int
+		length int
+	}{
+		{"?len=32", http.StatusOK, 32},
+		{"?len=0", http.StatusOK, 0},
+		{"?len=64", http.StatusOK, 64},
+		{"?len=65", http.StatusRequestEntityTooLarge, -1},
+		{"?len=9223372036854775807", http.StatusRequestEntityTooLarge, -1},
+		{"?len=99999999999999999999", http.StatusBadRequest, -1},
+		{"?len=-1", http.StatusBadRequest, -1},
+		{"?len=abc", http.StatusBadRequest, -1},
+		{"", http.StatusBadRequest, -1},
+	} {
+		req := httptest.NewRequest("GET", "/random"+test.query, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != test.status {
+			t.Errorf("%q: wrong status %d", test.query, rec.Code)
+			continue
+		}
+		if test.length < 0 {
+			continue
+		}
+		if rec.Body.Len() != test.length {
+			t.Errorf("%q: wrong length %d", test.query, rec.Body.Len())
+		}
+		ct := rec.Header().Get("Content-Type")
+		if ct != "application/octet-stream" {
+			t.Errorf("%q: wrong content type %q", test.query, ct)
+		}
+	}
+
+	acc.SetTruncateReads(true)
+	for _, query := range []string{"?len=100", "?len=9223372036854775807"} {
+		req := httptest.NewRequest("GET", "/random"+query, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.Len() != 64 {
+			t.Errorf("%q: truncation failed: status %d, %d bytes",
+				query, rec.Code, rec.Body.Len())
+		}
+	}
+}
+
+func TestHTTPHandlerDefaultLimit(t *testing.T) {
+	acc, _ := NewRNG("")
+	defer acc.Close()
+	handler := NewHTTPHandler(acc)
+
+	for _, test := range []struct {
+		query  string
+		status int
+	}{
+		{"?len=65536", http.StatusOK},
+		{"?len=65537", http.StatusRequestEntityTooLarge},
+		{"?len=9223372036854775807", http.StatusRequestEntityTooLarge},
+	} {
+		req := httptest.NewRequest("GET", "/random"+test.query, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.status {
+			t.Errorf("%q: wrong status %d", test.query, rec.Code)
+		}
+	}
+}
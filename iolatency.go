@@ -0,0 +1,90 @@
+// iolatency.go - use I/O timing as a source of entropy
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"io"
+	"time"
+)
+
+type latencyReader struct {
+	acc    *Accumulator
+	source uint8
+	seq    uint
+	r      io.Reader
+}
+
+func (lr *latencyReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := lr.r.Read(p)
+	dt := time.Since(start)
+
+	lr.acc.addRandomEvent(lr.source, lr.seq, int64ToBytes(int64(dt)))
+	lr.seq++
+	return n, err
+}
+
+type latencyWriter struct {
+	acc    *Accumulator
+	source uint8
+	seq    uint
+	w      io.Writer
+}
+
+func (lw *latencyWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := lw.w.Write(p)
+	dt := time.Since(start)
+
+	lw.acc.addRandomEvent(lw.source, lw.seq, int64ToBytes(int64(dt)))
+	lw.seq++
+	return n, err
+}
+
+// NewLatencyReader returns an io.Reader which reads data from r and
+// submits the time taken by every call to r.Read() to the
+// Accumulator's entropy pools.  This allows to opportunistically
+// collect entropy from I/O which a program performs anyway, for
+// example disk or network reads.  The data read is passed through
+// unchanged.
+//
+// Only the lowest bits of each timing measurement are difficult to
+// predict for an attacker, and on an idle system even these may be
+// guessable.  A conservative estimate is that each operation
+// contributes at most one or two bits of entropy.  The returned
+// reader is not safe for concurrent use.
+func (acc *Accumulator) NewLatencyReader(r io.Reader) io.Reader {
+	return &latencyReader{
+		acc:    acc,
+		source: acc.allocateSource(),
+		r:      r,
+	}
+}
+
+// NewLatencyWriter returns an io.Writer which writes data to w and
+// submits the time taken by every call to w.Write() to the
+// Accumulator's entropy pools.  See the documentation of
+// NewLatencyReader() for information about the amount of entropy
+// collected this way.  The returned writer is not safe for concurrent
+// use.
+func (acc *Accumulator) NewLatencyWriter(w io.Writer) io.Writer {
+	return &latencyWriter{
+		acc:    acc,
+		source: acc.allocateSource(),
+		w:      w,
+	}
+}
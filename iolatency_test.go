@@ -0,0 +1,92 @@
+// iolatency_test.go - unit tests for iolatency.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// slowFile is an in-memory file where every operation takes a
+// different amount of time.
+type slowFile struct {
+	bytes.Buffer
+	delay time.Duration
+}
+
+func (f *slowFile) wait() {
+	f.delay = (f.delay*7 + 13*time.Microsecond) % (500 * time.Microsecond)
+	time.Sleep(f.delay)
+}
+
+func (f *slowFile) Read(p []byte) (int, error) {
+	f.wait()
+	return f.Buffer.Read(p)
+}
+
+func (f *slowFile) Write(p []byte) (int, error) {
+	f.wait()
+	return f.Buffer.Write(p)
+}
+
+func poolTotal(acc *Accumulator) int {
+	acc.poolMutex.Lock()
+	defer acc.poolMutex.Unlock()
+	total := 0
+	for _, size := range acc.poolSize {
+		total += size
+	}
+	return total
+}
+
+func TestLatency(t *testing.T) {
+	acc, _ := NewRNG("")
+	file := &slowFile{}
+
+	w := acc.NewLatencyWriter(file)
+	for i := 0; i < 10; i++ {
+		n, err := w.Write([]byte{byte(i)})
+		if n != 1 || err != nil {
+			t.Fatalf("write failed: %d %v", n, err)
+		}
+	}
+	if total := poolTotal(acc); total != 10*(2+8) {
+		t.Errorf("wrong amount of entropy after writing: %d", total)
+	}
+
+	r := acc.NewLatencyReader(file)
+	buf := make([]byte, 3)
+	var data []byte
+	for {
+		n, err := r.Read(buf)
+		data = append(data, buf[:n]...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bytes.Compare(data, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) != 0 {
+		t.Errorf("wrong data read: %v", data)
+	}
+	// 4 reads return data, one more read returns io.EOF
+	if total := poolTotal(acc); total != 15*(2+8) {
+		t.Errorf("wrong amount of entropy after reading: %d", total)
+	}
+}
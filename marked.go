@@ -0,0 +1,133 @@
+// marked.go - random data streams with integrity markers
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"io"
+)
+
+// MarkerSize is the size of the markers in a marked stream, in bytes.
+const MarkerSize = sha256.Size
+
+var (
+	// ErrBadMarker is returned by VerifyMarkedStream if a marker does
+	// not match the preceding chunk.
+	ErrBadMarker = errors.New("marked stream: marker mismatch")
+
+	// ErrInvalidChunkSize is returned by VerifyMarkedStream if the
+	// chunk size is not positive.
+	ErrInvalidChunkSize = errors.New("marked stream: invalid chunk size")
+)
+
+// MarkedStream writes random data in chunks, each followed by a
+// marker which allows the receiver to verify the integrity of the
+// stream, using VerifyMarkedStream.  The stream is a sequence of
+// frames, numbered 0, 1, 2, ..., and frame i has the layout
+//
+//     frame_i = chunk_i || HMAC-SHA256(key, uint64be(i) || chunk_i)
+//
+// where chunk_i consists of chunkSize random bytes, uint64be(i) is the
+// frame number encoded as 8 bytes, big-endian, and || denotes
+// concatenation.  Each frame thus has chunkSize+MarkerSize bytes.
+// Including the frame number in the HMAC input ensures that frames
+// cannot be reordered or duplicated without detection.  Removal of
+// frames from the end of the stream cannot be detected.
+//
+// The HMAC key is drawn from the generator when the MarkedStream is
+// created, so that different streams from the same generator use
+// different keys and frames cannot be moved between streams.  The key
+// must be passed to the receiver over a separate, secure channel.  The
+// markers only protect the integrity of the stream; they do not hide
+// the data.
+type MarkedStream struct {
+	gen       *Generator
+	key       []byte
+	chunkSize int
+	frame     uint64
+}
+
+// NewMarkedStream returns a MarkedStream which takes its data from
+// gen and writes chunks of chunkSize bytes.  NewMarkedStream panics
+// if chunkSize is not positive.
+func NewMarkedStream(gen *Generator, chunkSize int) *MarkedStream {
+	if chunkSize <= 0 {
+		panic("invalid chunk size")
+	}
+	return &MarkedStream{
+		gen:       gen,
+		key:       gen.PseudoRandomData(MarkerSize),
+		chunkSize: chunkSize,
+	}
+}
+
+// Key returns the HMAC key used for the markers.  This is required by
+// the receiver to verify the stream.
+func (m *MarkedStream) Key() []byte {
+	return append([]byte(nil), m.key...)
+}
+
+// computeMarker returns the marker for the given frame.
+func computeMarker(key []byte, frame uint64, chunk []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(uint64ToBytes(frame))
+	mac.Write(chunk)
+	return mac.Sum(nil)
+}
+
+// WriteFrames writes n frames, each consisting of a chunk of random
+// data and its marker, to w.
+func (m *MarkedStream) WriteFrames(w io.Writer, n int) error {
+	for i := 0; i < n; i++ {
+		chunk := m.gen.PseudoRandomData(uint(m.chunkSize))
+		marker := computeMarker(m.key, m.frame, chunk)
+		m.frame++
+		_, err := w.Write(append(chunk, marker...))
+		wipe(chunk)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyMarkedStream reads a stream written by a MarkedStream from r
+// until the end of input, and checks all markers using the given key
+// and chunk size.  The number of valid frames read is returned.  If a
+// marker does not match, ErrBadMarker is returned; if the stream ends
+// in the middle of a frame, io.ErrUnexpectedEOF is returned.  If
+// chunkSize is not positive, ErrInvalidChunkSize is returned.
+func VerifyMarkedStream(r io.Reader, key []byte, chunkSize int) (int, error) {
+	if chunkSize <= 0 {
+		return 0, ErrInvalidChunkSize
+	}
+	buf := make([]byte, chunkSize+MarkerSize)
+	for frame := 0; ; frame++ {
+		_, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return frame, nil
+		} else if err != nil {
+			return frame, err
+		}
+		chunk, marker := buf[:chunkSize], buf[chunkSize:]
+		if !hmac.Equal(marker, computeMarker(key, uint64(frame), chunk)) {
+			return frame, ErrBadMarker
+		}
+	}
+}
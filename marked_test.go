@@ -0,0 +1,99 @@
+// marked_test.go - unit tests for marked.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"bytes"
+	"crypto/aes"
+	"io"
+	"testing"
+)
+
+func TestMarkedStream(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	chunkSize := 100
+	m := NewMarkedStream(gen, chunkSize)
+	buf := &bytes.Buffer{}
+	if err := m.WriteFrames(buf, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFrames(buf, 2); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	frameSize := chunkSize + MarkerSize
+	if len(data) != 5*frameSize {
+		t.Fatalf("wrong stream length %d", len(data))
+	}
+
+	n, err := VerifyMarkedStream(bytes.NewReader(data), m.Key(), chunkSize)
+	if n != 5 || err != nil {
+		t.Errorf("valid stream rejected: %d, %v", n, err)
+	}
+
+	tampered := append([]byte(nil), data...)
+	tampered[2*frameSize+10] ^= 1
+	n, err = VerifyMarkedStream(bytes.NewReader(tampered), m.Key(), chunkSize)
+	if n != 2 || err != ErrBadMarker {
+		t.Errorf("tampered stream: %d, %v", n, err)
+	}
+
+	// swap the first two frames
+	swapped := append([]byte(nil), data[frameSize:2*frameSize]...)
+	swapped = append(swapped, data[:frameSize]...)
+	n, err = VerifyMarkedStream(bytes.NewReader(swapped), m.Key(), chunkSize)
+	if n != 0 || err != ErrBadMarker {
+		t.Errorf("reordered stream: %d, %v", n, err)
+	}
+
+	wrongKey := make([]byte, MarkerSize)
+	_, err = VerifyMarkedStream(bytes.NewReader(data), wrongKey, chunkSize)
+	if err != ErrBadMarker {
+		t.Errorf("wrong key accepted: %v", err)
+	}
+
+	_, err = VerifyMarkedStream(bytes.NewReader(data[:len(data)-1]),
+		m.Key(), chunkSize)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated frame: %v", err)
+	}
+
+	for _, size := range []int{0, -1} {
+		_, err = VerifyMarkedStream(bytes.NewReader(data), m.Key(), size)
+		if err != ErrInvalidChunkSize {
+			t.Errorf("chunk size %d: %v", size, err)
+		}
+	}
+}
+
+func TestMarkedStreamKeys(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	m1 := NewMarkedStream(gen, 16)
+	m2 := NewMarkedStream(gen, 16)
+	if bytes.Equal(m1.Key(), m2.Key()) {
+		t.Fatal("two streams from one generator share a key")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := m1.WriteFrames(buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	_, err := VerifyMarkedStream(buf, m2.Key(), 16)
+	if err != ErrBadMarker {
+		t.Errorf("frame from another stream verified: %v", err)
+	}
+}
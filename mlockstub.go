@@ -0,0 +1,26 @@
+// +build !darwin,!linux
+
+package fortuna
+
+import (
+	"errors"
+)
+
+var (
+	errMlockUnsupported = errors.New("memory locking not supported")
+)
+
+// mlock is a dummy function which always returns an error on this
+// system.
+//
+// On systems which support memory locking, mlock() locks the pages
+// containing the given memory into RAM.
+func mlock(b []byte) error {
+	return errMlockUnsupported
+}
+
+// munlock is a dummy function which always returns an error on this
+// system.
+func munlock(b []byte) error {
+	return errMlockUnsupported
+}
@@ -0,0 +1,21 @@
+// +build darwin linux
+
+package fortuna
+
+import (
+	"syscall"
+)
+
+// mlock locks the pages containing the given memory into RAM.
+//
+// The mlock() function is not available on all operating systems.  On
+// systems where mlock() is not available, it is replaced with a stub
+// function which always returns an error.
+func mlock(b []byte) error {
+	return syscall.Mlock(b)
+}
+
+// munlock unlocks memory which was previously locked using mlock().
+func munlock(b []byte) error {
+	return syscall.Munlock(b)
+}
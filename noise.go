@@ -0,0 +1,84 @@
+// noise.go - reproducible value noise for procedural generation
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/cipher"
+	"errors"
+
+	"github.com/seehuhn/sha256d"
+)
+
+// noisePrefix is hashed together with the seed to obtain the key of a
+// ValueNoise, so that its output differs from a DeterministicStream
+// with the same seed.
+var noisePrefix = []byte("fortuna value noise\x00")
+
+// ErrBlockSize is returned by NewValueNoise if the block cipher does
+// not use 16 byte blocks.
+var ErrBlockSize = errors.New("block cipher must use 16 byte blocks")
+
+// ValueNoise assigns reproducible pseudo-random values to the points
+// of the integer lattice, for use in procedural generation, e.g. as
+// the basis for value noise textures.  The value for each point is
+// obtained by encrypting the packed coordinates with a block cipher
+// keyed by a hash of the seed.  Values for different points are
+// independent; smooth noise can be obtained by interpolating between
+// lattice points.  Like DeterministicStream, ValueNoise is meant for
+// reproducible data, not for secrets.
+//
+// The methods of ValueNoise do not modify its state, so a ValueNoise
+// can be used concurrently from different goroutines.
+type ValueNoise struct {
+	cipher cipher.Block
+}
+
+// NewValueNoise returns a ValueNoise for the given seed.  The function
+// newCipher should normally be aes.NewCipher; the cipher must use
+// 16 byte blocks, otherwise ErrBlockSize is returned.  Objects
+// constructed with the same cipher and seed return identical values.
+func NewValueNoise(newCipher NewCipher, seed []byte) (*ValueNoise, error) {
+	hash := sha256d.New()
+	hash.Write(noisePrefix)
+	hash.Write(seed)
+	key := hash.Sum(nil)
+	block, err := newCipher(key)
+	wipe(key)
+	if err != nil {
+		return nil, err
+	}
+	if block.BlockSize() != 16 {
+		return nil, ErrBlockSize
+	}
+	return &ValueNoise{cipher: block}, nil
+}
+
+// Noise2D returns the value assigned to the lattice point (x, y).
+// The result is uniformly distributed on the interval [0, 1), and
+// is a deterministic function of the seed and the coordinates.
+func (v *ValueNoise) Noise2D(x, y int) float64 {
+	var buf [16]byte
+	ux, uy := uint64(x), uint64(y)
+	for i := 0; i < 8; i++ {
+		buf[i] = byte(ux)
+		buf[8+i] = byte(uy)
+		ux >>= 8
+		uy >>= 8
+	}
+	v.cipher.Encrypt(buf[:], buf[:])
+	return float64(bytesToUint64(buf[:8])>>11) / (1 << 53)
+}
@@ -0,0 +1,71 @@
+// noise_test.go - unit tests for noise.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/aes"
+	"math"
+	"testing"
+)
+
+func TestNoise2D(t *testing.T) {
+	seed := []byte("texture")
+	v1, err := NewValueNoise(aes.NewCipher, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, _ := NewValueNoise(aes.NewCipher, seed)
+	v3, _ := NewValueNoise(aes.NewCipher, []byte("other"))
+
+	for _, p := range [][2]int{{0, 0}, {1, 0}, {0, 1}, {-1, 5}, {1 << 40, -7}} {
+		a := v1.Noise2D(p[0], p[1])
+		if a < 0 || a >= 1 {
+			t.Errorf("value %g at %v out of range", a, p)
+		}
+		if v1.Noise2D(p[0], p[1]) != a || v2.Noise2D(p[0], p[1]) != a {
+			t.Errorf("value at %v not reproducible", p)
+		}
+		if v3.Noise2D(p[0], p[1]) == a {
+			t.Errorf("different seeds give the same value at %v", p)
+		}
+	}
+
+	// Compute the correlation between horizontal and vertical
+	// neighbours on a grid.
+	n := 200
+	var sum, sumSq, sumX, sumY float64
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			a := v1.Noise2D(x, y) - 0.5
+			sum += a
+			sumSq += a * a
+			sumX += a * (v1.Noise2D(x+1, y) - 0.5)
+			sumY += a * (v1.Noise2D(x, y+1) - 0.5)
+		}
+	}
+	m := float64(n * n)
+	if mean := sum/m + 0.5; math.Abs(mean-0.5) > 0.01 {
+		t.Errorf("wrong mean %g", mean)
+	}
+	// For independent values, the correlation is approximately
+	// normal with standard deviation 1/n.
+	for _, c := range []float64{sumX / sumSq, sumY / sumSq} {
+		if math.Abs(c) > 4/float64(n) {
+			t.Errorf("neighbours are correlated: %g", c)
+		}
+	}
+}
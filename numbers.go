@@ -0,0 +1,128 @@
+// numbers.go - random numbers from different ranges
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"errors"
+	"math/big"
+)
+
+var (
+	// ErrInvalidModulus is returned by RandomMod if the modulus is
+	// not positive.
+	ErrInvalidModulus = errors.New("modulus must be positive")
+)
+
+// RandomMod returns a random integer, uniformly distributed on the
+// range 0, 1, ..., modulus-1.  A typical use is to sample a secret
+// scalar modulo a prime.  If modulus is not positive,
+// ErrInvalidModulus is returned.
+//
+// Candidate values are drawn using the minimal number of random bits
+// and values outside the range are rejected, so the result is free of
+// modulo bias.  On average, fewer than two candidates are required.
+func (gen *Generator) RandomMod(modulus *big.Int) (*big.Int, error) {
+	if modulus.Sign() <= 0 {
+		return nil, ErrInvalidModulus
+	}
+
+	max := new(big.Int).Sub(modulus, big.NewInt(1))
+	bits := uint(max.BitLen())
+	if bits == 0 {
+		return new(big.Int), nil
+	}
+	numBytes := (bits + 7) / 8
+	topMask := byte(0xff >> (8*numBytes - bits))
+
+	res := new(big.Int)
+	for {
+		buf := gen.PseudoRandomData(numBytes)
+		buf[0] &= topMask
+		res.SetBytes(buf)
+		wipe(buf)
+		if res.Cmp(modulus) < 0 {
+			return res, nil
+		}
+	}
+}
+
+// intn returns a random integer, uniformly distributed on the range
+// 0, 1, ..., n-1.  If n is a power of two, the result is obtained by
+// masking a single 63 bit random value.  Otherwise, values from the
+// incomplete final stretch of n values are rejected, so that the
+// result is free of modulo bias.
+func (gen *Generator) intn(n int) int {
+	if n <= 0 {
+		panic("invalid argument to intn")
+	}
+	if n&(n-1) == 0 {
+		return int(gen.Int63() & int64(n-1))
+	}
+	max := int64((1 << 63) - 1 - (1<<63)%uint64(n))
+	for {
+		v := gen.Int63()
+		if v <= max {
+			return int(v % int64(n))
+		}
+	}
+}
+
+// Roll simulates the roll of a fair die with the given number of
+// sides.  The result is uniformly distributed on the range 1, 2, ...,
+// sides; rejection sampling is used to avoid any bias.  Roll panics
+// if sides is less than 1.
+func (gen *Generator) Roll(sides int) int {
+	if sides < 1 {
+		panic("dice must have at least one side")
+	}
+	return gen.intn(sides) + 1
+}
+
+// RollMany returns the results of count independent rolls of a fair
+// die with the given number of sides.  See .Roll() for details.
+func (gen *Generator) RollMany(count, sides int) []int {
+	if sides < 1 {
+		panic("dice must have at least one side")
+	}
+	res := make([]int, count)
+	for i := range res {
+		res[i] = gen.intn(sides) + 1
+	}
+	return res
+}
+
+// float64 returns a random float64, uniformly distributed on the
+// interval [0, 1).  All 2^53 possible values are equally likely.
+func (gen *Generator) float64() float64 {
+	return float64(gen.Int63()>>10) / (1 << 53)
+}
+
+// SampleInverseCDF returns a random sample from the distribution with
+// the given inverse cumulative distribution function.  This is done
+// by computing inverseCDF(U), where U is uniformly distributed on the
+// interval [0, 1).  The function inverseCDF must be defined on all
+// of [0, 1); in particular, inverseCDF(0) must be a valid sample.
+//
+// For example, a standard exponentially distributed sample can be
+// obtained as follows:
+//
+//     x := gen.SampleInverseCDF(func(u float64) float64 {
+//         return -math.Log(1 - u)
+//     })
+func (gen *Generator) SampleInverseCDF(inverseCDF func(u float64) float64) float64 {
+	return inverseCDF(gen.float64())
+}
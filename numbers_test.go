@@ -0,0 +1,146 @@
+// numbers_test.go - unit tests for numbers.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/aes"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestRandomMod(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+
+	// 11 is a worst case for the rejection step: the 4 bits used per
+	// candidate also represent the 5 values 11, ..., 15.
+	m := 11
+	n := 110000
+	counts := make([]int, m)
+	for i := 0; i < n; i++ {
+		x, err := gen.RandomMod(big.NewInt(int64(m)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.Sign() < 0 || x.Cmp(big.NewInt(int64(m))) >= 0 {
+			t.Fatalf("result %v out of range", x)
+		}
+		counts[x.Int64()]++
+	}
+	p := 1 / float64(m)
+	for i, k := range counts {
+		d := (float64(k) - p*float64(n)) / math.Sqrt(p*(1-p)*float64(n))
+		if math.Abs(d) >= 4 {
+			t.Errorf("value %d occurs %d times out of %d", i, k, n)
+		}
+	}
+
+	x, err := gen.RandomMod(big.NewInt(1))
+	if err != nil || x.Sign() != 0 {
+		t.Errorf("wrong result %v, %v for modulus 1", x, err)
+	}
+
+	for _, m := range []int64{0, -7} {
+		_, err := gen.RandomMod(big.NewInt(m))
+		if err != ErrInvalidModulus {
+			t.Errorf("modulus %d not rejected", m)
+		}
+	}
+}
+
+func TestFloat64(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+	for i := 0; i < 10000; i++ {
+		x := gen.float64()
+		if x < 0 || x >= 1 {
+			t.Fatalf("result %g out of range", x)
+		}
+	}
+}
+
+func TestSampleInverseCDF(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+	exponential := func(u float64) float64 {
+		return -math.Log(1 - u)
+	}
+
+	// Compare the empirical distribution function to the
+	// exponential distribution at a few points.
+	n := 100000
+	points := []float64{0.1, 0.5, 1, 2, 4}
+	counts := make([]int, len(points))
+	for i := 0; i < n; i++ {
+		x := gen.SampleInverseCDF(exponential)
+		for j, p := range points {
+			if x <= p {
+				counts[j]++
+			}
+		}
+	}
+	for j, p := range points {
+		q := 1 - math.Exp(-p)
+		d := (float64(counts[j]) - q*float64(n)) / math.Sqrt(q*(1-q)*float64(n))
+		if math.Abs(d) >= 4 {
+			t.Errorf("P(X <= %g) = %g, expected %g",
+				p, float64(counts[j])/float64(n), q)
+		}
+	}
+}
+
+func TestRoll(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+
+	n := 60000
+	for _, sides := range []int{1, 6, 8, 20} {
+		counts := make([]int, sides+1)
+		for _, x := range gen.RollMany(n, sides) {
+			if x < 1 || x > sides {
+				t.Fatalf("roll of d%d gave %d", sides, x)
+			}
+			counts[x]++
+		}
+		p := 1 / float64(sides)
+		for face, count := range counts[1:] {
+			d := (float64(count) - p*float64(n)) / math.Sqrt(p*(1-p)*float64(n)+1e-9)
+			if math.Abs(d) >= 4 {
+				t.Errorf("d%d: face %d came up %d times", sides, face+1, count)
+			}
+		}
+	}
+
+	// For powers of two, a single value is drawn and masked.
+	ref := NewGenerator(aes.NewCipher)
+	ref.Seed(2)
+	gen.Seed(2)
+	for i := 0; i < 100; i++ {
+		expected := int(ref.Int63()&7) + 1
+		if x := gen.Roll(8); x != expected {
+			t.Fatalf("Roll(8) = %d, expected %d", x, expected)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Roll(0) did not panic")
+		}
+	}()
+	gen.Roll(0)
+}
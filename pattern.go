@@ -0,0 +1,240 @@
+// pattern.go - random strings matching a pattern
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPattern is returned by RandomMatching if the pattern
+// cannot be parsed.
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// maxRepeat limits the counts which can be given in a pattern, to
+// protect against patterns which would use excessive memory.
+const maxRepeat = 1000
+
+const (
+	digitChars = "0123456789"
+	wordChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_"
+)
+
+// patternItem describes one element of a parsed pattern: between min
+// and max characters, chosen from alphabet.
+type patternItem struct {
+	alphabet string
+	min, max int
+}
+
+// parsePattern translates a pattern, as described for
+// .RandomMatching(), into a list of items.
+func parsePattern(pattern string) ([]patternItem, error) {
+	var items []patternItem
+	p := pattern
+	for len(p) > 0 {
+		var alphabet string
+		switch c := p[0]; {
+		case c == '[':
+			end := strings.IndexByte(p[1:], ']')
+			if end < 0 {
+				return nil, ErrInvalidPattern
+			}
+			var err error
+			alphabet, err = parseClass(p[1 : end+1])
+			if err != nil {
+				return nil, err
+			}
+			p = p[end+2:]
+		case c == '\\':
+			if len(p) < 2 {
+				return nil, ErrInvalidPattern
+			}
+			var err error
+			alphabet, err = parseEscape(p[1])
+			if err != nil {
+				return nil, err
+			}
+			p = p[2:]
+		case c == '.':
+			var chars []byte
+			for x := byte(' '); x <= '~'; x++ {
+				chars = append(chars, x)
+			}
+			alphabet = string(chars)
+			p = p[1:]
+		case c >= 0x80 || strings.IndexByte("]{}?*+()|^$", c) >= 0:
+			return nil, ErrInvalidPattern
+		default:
+			alphabet = p[:1]
+			p = p[1:]
+		}
+
+		item := patternItem{alphabet: alphabet, min: 1, max: 1}
+		if len(p) > 0 && p[0] == '?' {
+			item.min = 0
+			p = p[1:]
+		} else if len(p) > 0 && p[0] == '{' {
+			end := strings.IndexByte(p, '}')
+			if end < 0 {
+				return nil, ErrInvalidPattern
+			}
+			var err error
+			item.min, item.max, err = parseCount(p[1:end])
+			if err != nil {
+				return nil, err
+			}
+			p = p[end+1:]
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseEscape returns the characters described by the escape
+// sequence consisting of a backslash followed by c.  Only \d, \w and
+// escaped ASCII punctuation are allowed; other letters and digits
+// have special meanings in regular expressions, which are not
+// supported here.
+func parseEscape(c byte) (string, error) {
+	switch {
+	case c == 'd':
+		return digitChars, nil
+	case c == 'w':
+		return wordChars, nil
+	case c >= 0x80 || strings.IndexByte(wordChars, c) >= 0:
+		return "", ErrInvalidPattern
+	}
+	return string([]byte{c}), nil
+}
+
+// parseClass returns the characters described by the inside of a
+// character class, e.g. "A-Z0-9_".
+func parseClass(class string) (string, error) {
+	if strings.HasPrefix(class, "^") {
+		// negated classes are not supported
+		return "", ErrInvalidPattern
+	}
+	seen := [256]bool{}
+	var chars []byte
+	add := func(c byte) {
+		if !seen[c] {
+			seen[c] = true
+			chars = append(chars, c)
+		}
+	}
+	for i := 0; i < len(class); i++ {
+		c := class[i]
+		if c == '\\' {
+			if i+1 >= len(class) {
+				return "", ErrInvalidPattern
+			}
+			i++
+			esc, err := parseEscape(class[i])
+			if err != nil {
+				return "", err
+			}
+			for _, x := range []byte(esc) {
+				add(x)
+			}
+			continue
+		}
+		if c >= 0x80 {
+			return "", ErrInvalidPattern
+		}
+		if i+2 < len(class) && class[i+1] == '-' {
+			last := class[i+2]
+			if last < c || last >= 0x80 {
+				return "", ErrInvalidPattern
+			}
+			for x := int(c); x <= int(last); x++ {
+				add(byte(x))
+			}
+			i += 2
+			continue
+		}
+		add(c)
+	}
+	if len(chars) == 0 {
+		return "", ErrInvalidPattern
+	}
+	return string(chars), nil
+}
+
+// parseCount parses the inside of a count, i.e. "n" or "m,n".
+func parseCount(count string) (int, int, error) {
+	parts := strings.Split(count, ",")
+	if len(parts) > 2 {
+		return 0, 0, ErrInvalidPattern
+	}
+	min, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, ErrInvalidPattern
+	}
+	max := min
+	if len(parts) == 2 {
+		max, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, 0, ErrInvalidPattern
+		}
+	}
+	if min < 0 || max < min || max > maxRepeat {
+		return 0, 0, ErrInvalidPattern
+	}
+	return min, max, nil
+}
+
+// RandomMatching returns a random string which matches the given
+// pattern.  The pattern language is a small subset of the regular
+// expression syntax:
+//
+//     x        the literal ASCII character x
+//     \x       the literal punctuation character x, e.g. \. or \[
+//     \d       a digit, 0-9
+//     \w       a letter, digit or underscore
+//     .        a printable ASCII character, including space
+//     [...]    one character from the class, e.g. [A-Z0-9_]; ranges
+//              and the escapes \d and \w may be used inside classes
+//
+// Each of these may be followed by a count: "?" for zero or one
+// occurrences, "{n}" for exactly n and "{m,n}" for between m and n
+// occurrences; counts are limited to 1000.  Alternatives, groups,
+// anchors, negated classes, the unbounded repetitions "*" and "+",
+// and escapes like \s or \D, where the backslash is followed by a
+// letter or digit other than d and w, are not supported.  If the
+// pattern cannot be parsed, ErrInvalidPattern is returned.
+//
+// Every character is chosen uniformly from its class, and every
+// number of repetitions is equally likely.  For example, the pattern
+// "[A-Z]{3}-\d{4}" produces strings like "KQX-0381".
+func (gen *Generator) RandomMatching(pattern string) (string, error) {
+	items, err := parsePattern(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	res := make([]byte, 0, len(pattern))
+	for _, item := range items {
+		n := item.min
+		if item.max > item.min {
+			n += gen.intn(item.max - item.min + 1)
+		}
+		res = append(res, gen.randomString(item.alphabet, n)...)
+	}
+	return string(res), nil
+}
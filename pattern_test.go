@@ -0,0 +1,92 @@
+// pattern_test.go - unit tests for pattern.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/aes"
+	"regexp"
+	"testing"
+)
+
+func TestRandomMatching(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+
+	for _, pattern := range []string{
+		"",
+		"abc",
+		`[A-Z]{3}[0-9]{4}`,
+		`[A-Z]{3}-\d{4}`,
+		`\w{2,8}@example\.com`,
+		`[a-f0-9]{32}`,
+		`colou?r`,
+		`[\d_x-z]{0,5}`,
+		`.{10}`,
+		`\[\]\{x\}`,
+	} {
+		re := regexp.MustCompile("^(?:" + pattern + ")$")
+		for i := 0; i < 100; i++ {
+			s, err := gen.RandomMatching(pattern)
+			if err != nil {
+				t.Fatalf("%q: %v", pattern, err)
+			}
+			if !re.MatchString(s) {
+				t.Fatalf("%q does not match %q", s, pattern)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		s, _ := gen.RandomMatching("[ab]{1,2}")
+		seen[s] = true
+	}
+	if len(seen) != 6 {
+		t.Errorf("not all strings generated: %v", seen)
+	}
+}
+
+func TestRandomMatchingInvalid(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	for _, pattern := range []string{
+		"[abc",
+		"[]",
+		"[z-a]",
+		"a{3",
+		"a{x}",
+		"a{3,2}",
+		"a{1,2,3}",
+		"a{5000}",
+		"a*",
+		"(ab)",
+		"a|b",
+		`abc\`,
+		"ä",
+		"[^a]",
+		"[^a-z]{3}",
+		`\s{5}`,
+		`\D{5}`,
+		`\1`,
+		`[\s]`,
+		`[a\S]`,
+	} {
+		_, err := gen.RandomMatching(pattern)
+		if err != ErrInvalidPattern {
+			t.Errorf("%q: expected ErrInvalidPattern, got %v", pattern, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// policy.go - decide when to reseed the generator
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"time"
+)
+
+// PoolState describes the state of the entropy pools of an
+// Accumulator, as seen by a ReseedPolicy.
+type PoolState struct {
+	// Sizes gives, for each entropy pool, the number of bytes
+	// submitted to the pool since the pool was last used for
+	// reseeding.
+	Sizes [numPools]int
+
+	// ReseedSize gives the number of bytes in pool 0 which count
+	// towards the reseed threshold.  This equals Sizes[0], unless the
+	// contribution of single sources is limited using
+	// Accumulator.SetMaxSourceFraction().
+	ReseedSize int
+
+	// ReseedCount gives the number of reseeds performed so far.
+	ReseedCount int
+}
+
+// ReseedPolicy decides when the generator of an Accumulator is
+// reseeded, and which entropy pools are used for the reseed.  The
+// method ShouldReseed is called every time random data is requested
+// from the Accumulator.  If the first return value is true, the
+// generator is reseeded using the pools listed in the second return
+// value; these pools are emptied afterwards.  The argument lastReseed
+// is the time of the previous reseed, or the zero time if no reseed
+// has happened yet.
+//
+// ShouldReseed is called while the Accumulator's pool lock is held,
+// so implementations must not call methods of the Accumulator.
+type ReseedPolicy interface {
+	ShouldReseed(pools PoolState, now time.Time, lastReseed time.Time) (bool, []int)
+}
+
+// specPolicy is the reseed policy from the description of Fortuna by
+// Ferguson and Schneier: a reseed happens once pool 0 contains at
+// least minPoolSize bytes and at least minReseedInterval has passed
+// since the previous reseed.  Pool i is used for every 2^i-th reseed.
+type specPolicy struct{}
+
+func (specPolicy) ShouldReseed(pools PoolState, now time.Time, lastReseed time.Time) (bool, []int) {
+	if pools.ReseedSize < minPoolSize ||
+		!now.After(lastReseed.Add(minReseedInterval)) {
+		return false, nil
+	}
+
+	count := pools.ReseedCount + 1
+	var use []int
+	for i := uint(0); i < numPools; i++ {
+		if count%(1<<i) != 0 {
+			break
+		}
+		use = append(use, int(i))
+	}
+	return true, use
+}
+
+// SetReseedPolicy replaces the rules which determine when the
+// Accumulator's generator is reseeded.  If policy is nil, the
+// default policy is restored.  The default policy follows the
+// description of Fortuna by Ferguson and Schneier: the generator is
+// reseeded once at least 32 bytes of entropy have been submitted to
+// pool 0 and at least 100ms have passed since the last reseed, and
+// pool i is used for every 2^i-th reseed.
+//
+// Changing the reseed policy can compromise the ability of the
+// generator to recover from a compromise of its state.  This method
+// is intended for advanced users only.
+func (acc *Accumulator) SetReseedPolicy(policy ReseedPolicy) {
+	if policy == nil {
+		policy = specPolicy{}
+	}
+	acc.poolMutex.Lock()
+	defer acc.poolMutex.Unlock()
+	acc.policy = policy
+}
@@ -0,0 +1,216 @@
+// policy_test.go - unit tests for policy.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSpecPolicy(t *testing.T) {
+	policy := specPolicy{}
+	now := time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	state := PoolState{ReseedSize: minPoolSize - 1}
+	if ok, _ := policy.ShouldReseed(state, now, time.Time{}); ok {
+		t.Error("reseed with too little entropy")
+	}
+	state.ReseedSize = minPoolSize
+	if ok, _ := policy.ShouldReseed(state, now, now.Add(-minReseedInterval)); ok {
+		t.Error("reseed too early")
+	}
+
+	for count, expected := range map[int][]int{
+		0: {0},
+		1: {0, 1},
+		2: {0},
+		3: {0, 1, 2},
+		7: {0, 1, 2, 3},
+	} {
+		state.ReseedCount = count
+		ok, pools := policy.ShouldReseed(state, now, time.Time{})
+		if !ok || !reflect.DeepEqual(pools, expected) {
+			t.Errorf("reseed %d: wrong pools %v", count+1, pools)
+		}
+	}
+}
+
+type alwaysReseed struct {
+	calls int
+}
+
+func (p *alwaysReseed) ShouldReseed(pools PoolState, now time.Time, lastReseed time.Time) (bool, []int) {
+	p.calls++
+	return true, []int{0, 5}
+}
+
+func TestCustomPolicy(t *testing.T) {
+	acc, _ := NewRNG("")
+	policy := &alwaysReseed{}
+	acc.SetReseedPolicy(policy)
+
+	for i := 1; i <= 10; i++ {
+		acc.addRandomEvent(0, 5, []byte{byte(i)})
+		acc.RandomData(1)
+		if acc.reseedCount != i {
+			t.Fatalf("policy not honoured: %d reseeds after %d draws",
+				acc.reseedCount, i)
+		}
+		if acc.poolSize[5] != 0 {
+			t.Fatal("pool not emptied")
+		}
+	}
+	if policy.calls != 10 {
+		t.Errorf("policy called %d times", policy.calls)
+	}
+
+	acc.SetReseedPolicy(nil)
+	acc.RandomData(1)
+	if acc.reseedCount != 10 {
+		t.Error("default policy not restored")
+	}
+}
+
+func TestLastReseedPools(t *testing.T) {
+	acc, _ := NewRNG("")
+	defer acc.Close()
+	now := time.Now()
+	acc.clock = func() time.Time { return now }
+
+	if pools := acc.LastReseedPools(); pools != nil {
+		t.Errorf("pools %v reported before first reseed", pools)
+	}
+
+	for count := 1; count <= 64; count++ {
+		acc.addRandomEvent(0, 0, make([]byte, minPoolSize))
+		now = now.Add(time.Second)
+		acc.RandomData(1)
+		if acc.reseedCount != count {
+			t.Fatalf("reseed %d did not happen", count)
+		}
+
+		pools := acc.LastReseedPools()
+		for i, pool := range pools {
+			if pool != i {
+				t.Fatalf("reseed %d: unexpected pools %v", count, pools)
+			}
+		}
+		// pool i is used iff 2^i divides the reseed count
+		expected := 0
+		for count%(1<<uint(expected)) == 0 {
+			expected++
+		}
+		if len(pools) != expected {
+			t.Errorf("reseed %d: used pools %v", count, pools)
+		}
+	}
+}
+
+func TestCustomPolicyClose(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	seedFileName := filepath.Join(tempDir, "seed")
+
+	acc, err := NewRNG(seedFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc.SetReseedPolicy(&alwaysReseed{})
+	acc.RandomData(1)
+
+	// Close() writes the seed file after the pools have been torn
+	// down; this must not consult the policy.
+	if err := acc.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+// scriptedEvent is one step of a reseed timing script.  At time at,
+// measured from the start of the script, either the given data is
+// submitted to the given pool, or, if data is nil, random output is
+// drawn.  For draws, reseed gives whether a reseed must happen.
+type scriptedEvent struct {
+	at     time.Duration
+	source uint8
+	pool   uint
+	data   []byte
+	reseed bool
+}
+
+// runScript feeds the events to a new Accumulator, using a fake clock
+// which is stepped to the time of each event, and checks that
+// reseeds happen exactly as specified.
+func runScript(t *testing.T, events []scriptedEvent) {
+	t.Helper()
+	acc, _ := NewRNG("")
+	defer acc.Close()
+	start := time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	acc.clock = func() time.Time { return now }
+
+	for i, ev := range events {
+		now = start.Add(ev.at)
+		if ev.data != nil {
+			acc.addRandomEvent(ev.source, ev.pool, ev.data)
+			continue
+		}
+		before := acc.reseedCount
+		acc.RandomData(1)
+		reseeded := acc.reseedCount != before
+		if reseeded != ev.reseed {
+			t.Errorf("event %d at %v: reseed=%t, expected %t",
+				i, ev.at, reseeded, ev.reseed)
+		}
+	}
+}
+
+func TestReseedTiming(t *testing.T) {
+	ms := time.Millisecond
+	// Events of 14 bytes count as 16 bytes, including the header.
+	half := make([]byte, minPoolSize/2-2)
+
+	runScript(t, []scriptedEvent{
+		// not enough entropy
+		{at: 0, data: half},
+		{at: 1 * ms},
+		// threshold reached
+		{at: 10 * ms, source: 1, data: half},
+		{at: 11 * ms, reseed: true},
+		// enough entropy, but too soon after the last reseed
+		{at: 20 * ms, data: half},
+		{at: 30 * ms, data: half},
+		{at: 40 * ms},
+		{at: 111 * ms},
+		// interval elapsed
+		{at: 112 * ms, reseed: true},
+		// interval elapsed, but entropy only in other pools
+		{at: 300 * ms, pool: 1, data: half},
+		{at: 300 * ms, pool: 1, data: half},
+		{at: 400 * ms},
+		{at: 500 * ms, data: half},
+		{at: 500 * ms, data: half},
+		{at: 501 * ms, reseed: true},
+		{at: 502 * ms},
+	})
+}
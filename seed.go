@@ -20,6 +20,9 @@ import (
 	"errors"
 	"io"
 	"os"
+	"time"
+
+	"github.com/seehuhn/sha256d"
 )
 
 const (
@@ -29,6 +32,7 @@ const (
 var (
 	ErrCorruptedSeed = errors.New("seed file corrupted")
 	ErrInsecureSeed  = errors.New("seed file with insecure permissions")
+	ErrNoEntropy     = errors.New("no entropy source succeeded")
 )
 
 func doWriteSeed(f *os.File, seed []byte) error {
@@ -101,3 +105,222 @@ func (acc *Accumulator) writeSeedFile() error {
 	seed := acc.RandomData(seedFileSize)
 	return doWriteSeed(acc.seedFile, seed)
 }
+
+// autoSaveSeed is called periodically to update the seed file.
+// Errors are passed to the handler set by .SetSeedWriteErrorHandler().
+func (acc *Accumulator) autoSaveSeed() {
+	err := acc.writeSeedFile()
+	if err == nil {
+		return
+	}
+	acc.genMutex.Lock()
+	handler := acc.seedErrorHandler
+	acc.genMutex.Unlock()
+	if handler != nil {
+		handler(err)
+	}
+}
+
+// SetSeedWriteErrorHandler registers a function which is called
+// whenever the periodic update of the seed file fails, for example
+// because the disk is full or the file permissions have changed.  The
+// handler is called from a background goroutine, with the error
+// returned by the failed write.  A nil handler, the default, causes
+// such errors to be ignored.
+//
+// A seed file which is no longer updated means that, after a
+// restart, the generator is seeded from stale data.  Operators should
+// use the handler to raise an alert.  Errors from the final update
+// in .Close() are returned by Close() and are not passed to the
+// handler.
+func (acc *Accumulator) SetSeedWriteErrorHandler(handler func(error)) {
+	acc.genMutex.Lock()
+	defer acc.genMutex.Unlock()
+	acc.seedErrorHandler = handler
+}
+
+// maxEmptyReads is the number of consecutive reads without data and
+// without error after which readSeed gives up on a source.
+const maxEmptyReads = 100
+
+// readSeed fills buf with data from r.  Unlike io.ReadFull, readSeed
+// does not trust the byte counts returned by r: invalid counts are
+// reported as io.ErrShortBuffer, and a reader which repeatedly
+// returns no data without an error fails with io.ErrNoProgress.
+func readSeed(r io.Reader, buf []byte) error {
+	pos, empty := 0, 0
+	for pos < len(buf) {
+		k, err := r.Read(buf[pos:])
+		if k < 0 || k > len(buf)-pos {
+			return io.ErrShortBuffer
+		}
+		pos += k
+		if pos == len(buf) {
+			break
+		}
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
+			return err
+		}
+		if k == 0 {
+			empty++
+			if empty >= maxEmptyReads {
+				return io.ErrNoProgress
+			}
+		} else {
+			empty = 0
+		}
+	}
+	return nil
+}
+
+// readSeedSources reads n bytes from each of the given sources in
+// parallel and returns the concatenation of all successful reads, in
+// the order the sources are given.  Sources which fail, which return
+// invalid byte counts, or which do not deliver the data before the
+// timeout expires are skipped.  If no source succeeds, or if n is not
+// positive, ErrNoEntropy is returned.
+func readSeedSources(sources []io.Reader, n int, timeout time.Duration) ([]byte, error) {
+	if n <= 0 {
+		return nil, ErrNoEntropy
+	}
+
+	type result struct {
+		idx  int
+		data []byte
+	}
+	// The channel is buffered, so that sources which are still
+	// running after the timeout can finish without blocking.
+	c := make(chan result, len(sources))
+	for i, r := range sources {
+		go func(i int, r io.Reader) {
+			buf := make([]byte, n)
+			err := readSeed(r, buf)
+			if err != nil {
+				wipe(buf)
+				buf = nil
+			}
+			c <- result{i, buf}
+		}(i, r)
+	}
+
+	data := make([][]byte, len(sources))
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+loop:
+	for pending := len(sources); pending > 0; pending-- {
+		select {
+		case res := <-c:
+			data[res.idx] = res.data
+		case <-timer.C:
+			break loop
+		}
+	}
+
+	var seed []byte
+	success := false
+	for _, buf := range data {
+		if buf != nil {
+			seed = append(seed, buf...)
+			wipe(buf)
+			success = true
+		}
+	}
+	if !success {
+		return nil, ErrNoEntropy
+	}
+	return seed, nil
+}
+
+// SeedStrategy is a function which obtains seed data for a new
+// Accumulator, see NewAccumulatorWithSources().  If no data can be
+// obtained, a non-nil error must be returned.
+type SeedStrategy func() ([]byte, error)
+
+// ReaderSeedStrategy returns a SeedStrategy which reads n bytes from
+// r.  For example, ReaderSeedStrategy(rand.Reader, 32) obtains seed
+// data from the crypto/rand package.
+func ReaderSeedStrategy(r io.Reader, n int) SeedStrategy {
+	return func() ([]byte, error) {
+		if n <= 0 {
+			return nil, ErrNoEntropy
+		}
+		buf := make([]byte, n)
+		err := readSeed(r, buf)
+		if err != nil {
+			wipe(buf)
+			return nil, err
+		}
+		return buf, nil
+	}
+}
+
+// FileSeedStrategy returns a SeedStrategy which reads n bytes from
+// the file with the given name, for example a hardware random number
+// generator device like "/dev/hwrng", or a seed file written by
+// another program.  The strategy fails if the file cannot be opened
+// or contains fewer than n bytes.  The seed file of the new
+// Accumulator itself is processed separately and should not be given
+// here.
+func FileSeedStrategy(fileName string, n int) SeedStrategy {
+	return func() ([]byte, error) {
+		fd, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		defer fd.Close()
+		return ReaderSeedStrategy(fd, n)()
+	}
+}
+
+// JitterSeedStrategy returns a SeedStrategy which measures the time
+// taken by a small computation the given number of times, and returns
+// the 32 byte SHA-256d hash of the measured durations.  The jitter of
+// these timings comes from caches, interrupts and frequency scaling,
+// and is hard to predict, but each sample contains at most a few
+// bits of entropy and the quality depends strongly on the hardware.
+// Many samples, e.g. several thousand, should be used.  Since the
+// result always has 32 bytes, this strategy alone satisfies the seed
+// size requirement of NewAccumulatorWithSources(); it is best used as
+// the last strategy, as a fallback for stronger sources.
+func JitterSeedStrategy(samples int) SeedStrategy {
+	return func() ([]byte, error) {
+		if samples <= 0 {
+			return nil, ErrNoEntropy
+		}
+		hash := sha256d.New()
+		work := make([]byte, 64)
+		inner := sha256d.New()
+		for i := 0; i < samples; i++ {
+			start := time.Now()
+			inner.Write(work)
+			work = inner.Sum(work[:0])
+			hash.Write(int64ToBytes(int64(time.Since(start))))
+		}
+		return hash.Sum(nil), nil
+	}
+}
+
+// runSeedStrategies tries the given strategies in order, until at
+// least keySize bytes of seed data have been obtained.  The data from
+// all successful strategies is concatenated.
+func runSeedStrategies(strategies []SeedStrategy) ([]byte, error) {
+	var seed []byte
+	for _, strategy := range strategies {
+		if len(seed) >= keySize {
+			break
+		}
+		data, err := strategy()
+		if err != nil {
+			continue
+		}
+		seed = append(seed, data...)
+		wipe(data)
+	}
+	if len(seed) < keySize {
+		wipe(seed)
+		return nil, ErrNoEntropy
+	}
+	return seed, nil
+}
@@ -18,10 +18,14 @@ package fortuna
 
 import (
 	"bytes"
+	"crypto/aes"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestSeedfile(t *testing.T) {
@@ -94,3 +98,251 @@ func TestSeedfile(t *testing.T) {
 		rng.Close()
 	}
 }
+
+type failingReader struct{}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+type blockingReader chan bool
+
+func (r blockingReader) Read(p []byte) (int, error) {
+	<-r
+	return 0, io.EOF
+}
+
+// badCountReader returns the given byte count on every read.
+type badCountReader int
+
+func (r badCountReader) Read(p []byte) (int, error) {
+	return int(r), nil
+}
+
+func TestSeedSources(t *testing.T) {
+	block := make(blockingReader)
+	defer close(block)
+
+	sources := []io.Reader{
+		failingReader{},
+		bytes.NewReader([]byte{1, 2, 3, 4}),
+		block,
+		bytes.NewReader([]byte{5, 6}), // too short
+		bytes.NewReader([]byte{7, 8, 9, 10, 11}),
+	}
+	seed, err := readSeedSources(sources, 4, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(seed, []byte{1, 2, 3, 4, 7, 8, 9, 10}) != 0 {
+		t.Errorf("wrong seed %v", seed)
+	}
+
+	sources = []io.Reader{failingReader{}, block}
+	_, err = readSeedSources(sources, 4, 100*time.Millisecond)
+	if err != ErrNoEntropy {
+		t.Errorf("wrong error %v", err)
+	}
+
+	// Readers returning invalid or zero counts must not crash the
+	// program and must not count as successful.
+	sources = []io.Reader{badCountReader(-1), badCountReader(100), badCountReader(0)}
+	_, err = readSeedSources(sources, 4, time.Second)
+	if err != ErrNoEntropy {
+		t.Errorf("bad readers: wrong error %v", err)
+	}
+	sources = []io.Reader{bytes.NewReader(nil)}
+	_, err = readSeedSources(sources, 0, time.Second)
+	if err != ErrNoEntropy {
+		t.Errorf("n=0: wrong error %v", err)
+	}
+
+	sources = []io.Reader{
+		failingReader{},
+		bytes.NewReader(make([]byte, 32)),
+	}
+	acc, err := NewAccumulatorWithReaders(aes.NewCipher, "", sources, 32, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc.RandomData(16)
+	acc.Close()
+
+	sources = []io.Reader{failingReader{}}
+	acc, err = NewAccumulatorWithReaders(aes.NewCipher, "", sources, 32, time.Second)
+	if acc != nil || err != ErrNoEntropy {
+		t.Errorf("failure of all sources not detected: %v", err)
+	}
+}
+
+func TestSeedfileRestart(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	seedFileName := filepath.Join(tempDir, "seed")
+
+	// Simulate several runs of a program, where the seed file is the
+	// only source of entropy.
+	seen := map[string]int{}
+	for run := 0; run < 4; run++ {
+		rng, err := NewRNG(seedFileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rng.gen.reset()
+		err = rng.updateSeedFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := rng.RandomData(1024)
+		err = rng.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < len(out); i += 16 {
+			block := string(out[i : i+16])
+			if prev, ok := seen[block]; ok {
+				t.Fatalf("run %d repeats output from run %d", run, prev)
+			}
+			seen[block] = run
+		}
+	}
+}
+
+func TestSeedStrategies(t *testing.T) {
+	calls := 0
+	counting := func() ([]byte, error) {
+		calls++
+		return make([]byte, 16), nil
+	}
+
+	seed, err := runSeedStrategies([]SeedStrategy{
+		ReaderSeedStrategy(failingReader{}, 32),
+		ReaderSeedStrategy(bytes.NewReader([]byte{1, 2, 3}), 32),
+		ReaderSeedStrategy(bytes.NewReader(bytes.Repeat([]byte{7}, 20)), 20),
+		counting,
+		counting,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seed) != 36 || seed[0] != 7 || seed[20] != 0 {
+		t.Errorf("wrong seed %v", seed)
+	}
+	if calls != 1 {
+		t.Errorf("wrong number of strategies used: %d", calls)
+	}
+
+	acc, err := NewAccumulatorWithSources(aes.NewCipher, "",
+		ReaderSeedStrategy(failingReader{}, 32),
+		ReaderSeedStrategy(bytes.NewReader(make([]byte, 32)), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc.RandomData(16)
+	acc.Close()
+
+	acc, err = NewAccumulatorWithSources(aes.NewCipher, "",
+		ReaderSeedStrategy(failingReader{}, 32), counting)
+	if acc != nil || err != ErrNoEntropy {
+		t.Errorf("insufficient seed not detected: %v", err)
+	}
+
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	seedFileName := filepath.Join(tempDir, "seed")
+	acc, err = NewAccumulatorWithSources(aes.NewCipher, seedFileName,
+		JitterSeedStrategy(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc.Close()
+	if info, err := os.Stat(seedFileName); err != nil || info.Size() != seedFileSize {
+		t.Errorf("seed file not written: %v", err)
+	}
+}
+
+func TestFileSeedStrategy(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	fileName := filepath.Join(tempDir, "data")
+	err = ioutil.WriteFile(fileName, []byte("0123456789"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := FileSeedStrategy(fileName, 8)()
+	if err != nil || string(data) != "01234567" {
+		t.Errorf("wrong data %q, %v", data, err)
+	}
+	if _, err := FileSeedStrategy(fileName, 11)(); err == nil {
+		t.Error("short file accepted")
+	}
+	_, err = FileSeedStrategy(filepath.Join(tempDir, "missing"), 8)()
+	if err == nil {
+		t.Error("missing file accepted")
+	}
+}
+
+func TestJitterSeedStrategy(t *testing.T) {
+	a, err := JitterSeedStrategy(1000)()
+	if err != nil || len(a) != 32 {
+		t.Fatalf("jitter strategy failed: %d bytes, %v", len(a), err)
+	}
+	b, _ := JitterSeedStrategy(1000)()
+	if bytes.Equal(a, b) {
+		t.Error("jitter strategy returned the same data twice")
+	}
+	if _, err := JitterSeedStrategy(0)(); err == nil {
+		t.Error("zero samples accepted")
+	}
+}
+
+func TestSeedWriteErrorHandler(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	seedFileName := filepath.Join(tempDir, "seed")
+
+	acc, err := NewRNG(seedFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	acc.SetSeedWriteErrorHandler(func(err error) {
+		errs = append(errs, err)
+	})
+	acc.autoSaveSeed()
+	if len(errs) != 0 {
+		t.Fatalf("handler called for successful write: %v", errs)
+	}
+
+	// Make further writes fail.
+	acc.seedFile.Close()
+	acc.autoSaveSeed()
+	if len(errs) != 1 || errs[0] == nil {
+		t.Errorf("handler not called for failed write: %v", errs)
+	}
+
+	acc.SetSeedWriteErrorHandler(nil)
+	acc.autoSaveSeed()
+	if len(errs) != 1 {
+		t.Error("handler called after removal")
+	}
+
+	if acc.Close() == nil {
+		t.Error("failed final write not reported by Close()")
+	}
+}
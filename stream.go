@@ -0,0 +1,165 @@
+// stream.go - a seekable, reproducible stream of pseudo-random bytes
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/seehuhn/sha256d"
+)
+
+// ErrInvalidSeek is returned by DeterministicStream.Seek() and
+// DeterministicStream.ReadAt() if the requested position is negative,
+// relative to the end of the stream, or too large to be represented.
+var ErrInvalidSeek = errors.New("invalid seek on deterministic stream")
+
+// DeterministicStream is an endless, reproducible stream of
+// pseudo-random bytes which allows random access.  The stream is
+// obtained by encrypting the block numbers 0, 1, 2, ... with a block
+// cipher in counter mode, keyed by the SHA-256d hash of a seed.
+// Unlike the output of a Generator, the stream is never rekeyed, so
+// that any position can be reached directly.  As a consequence, the
+// stream offers no forward secrecy: anybody who knows the seed can
+// reproduce every byte of the stream.  DeterministicStream is meant
+// for reproducible simulations and procedural data, not for keys.
+//
+// DeterministicStream implements the io.ReadSeeker and io.ReaderAt
+// interfaces.  The stream has no end, so seeking relative to
+// io.SeekEnd is not possible.  The methods of a DeterministicStream
+// are not safe for concurrent use, except for .ReadAt() as described
+// there.
+type DeterministicStream struct {
+	cipher cipher.Block
+	pos    int64
+
+	// buf holds the block containing the byte at pos.
+	buf      []byte
+	bufBlock int64
+	ctr      []byte
+}
+
+// NewDeterministicStream returns a DeterministicStream for the given
+// seed.  The function newCipher should normally be aes.NewCipher.
+// Streams constructed with the same cipher and seed produce identical
+// output.
+func NewDeterministicStream(newCipher NewCipher, seed []byte) (*DeterministicStream, error) {
+	hash := sha256d.New()
+	hash.Write(seed)
+	key := hash.Sum(nil)
+	block, err := newCipher(key)
+	wipe(key)
+	if err != nil {
+		return nil, err
+	}
+	blockSize := block.BlockSize()
+	return &DeterministicStream{
+		cipher:   block,
+		buf:      make([]byte, blockSize),
+		bufBlock: -1,
+		ctr:      make([]byte, blockSize),
+	}, nil
+}
+
+// loadBlock makes buf hold the output block with index k.  The block
+// index is used as the counter value, least significant byte first.
+func (s *DeterministicStream) loadBlock(k int64) {
+	if s.bufBlock == k {
+		return
+	}
+	x := uint64(k)
+	for i := range s.ctr {
+		s.ctr[i] = byte(x)
+		x >>= 8
+	}
+	s.cipher.Encrypt(s.buf, s.ctr)
+	s.bufBlock = k
+}
+
+// Read fills p with the stream data starting at the current position
+// and advances the position by len(p).  Read always fills the whole
+// of p; the only possible error is ErrInvalidSeek, returned without
+// reading any data if the new position would exceed math.MaxInt64.
+func (s *DeterministicStream) Read(p []byte) (n int, err error) {
+	if s.pos > math.MaxInt64-int64(len(p)) {
+		return 0, ErrInvalidSeek
+	}
+	blockSize := int64(len(s.buf))
+	for n < len(p) {
+		s.loadBlock(s.pos / blockSize)
+		k := copy(p[n:], s.buf[s.pos%blockSize:])
+		n += k
+		s.pos += int64(k)
+	}
+	return n, nil
+}
+
+// Seek sets the position for the next Read.  The arguments are
+// interpreted as described for the io.Seeker interface; whence must
+// be io.SeekStart or io.SeekCurrent.  Seek returns the new position,
+// measured in bytes from the start of the stream.
+func (s *DeterministicStream) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		if offset > math.MaxInt64-s.pos {
+			return s.pos, ErrInvalidSeek
+		}
+		pos = s.pos + offset
+	default:
+		return s.pos, ErrInvalidSeek
+	}
+	if pos < 0 {
+		return s.pos, ErrInvalidSeek
+	}
+	s.pos = pos
+	return pos, nil
+}
+
+// ReadAt fills p with the stream data starting at offset off.  Since
+// every block of the stream can be computed directly from its index,
+// the cost of ReadAt is proportional to len(p) and independent of
+// off; no data before off is generated.  ReadAt does not use or
+// change the position used by .Read() and .Seek().  Concurrent calls
+// to ReadAt are safe, provided the Encrypt method of the underlying
+// block cipher is safe for concurrent use; this is the case for the
+// AES implementation from crypto/aes.  If off is negative, or if
+// off+len(p) would exceed math.MaxInt64, ErrInvalidSeek is returned.
+func (s *DeterministicStream) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 || off > math.MaxInt64-int64(len(p)) {
+		return 0, ErrInvalidSeek
+	}
+
+	blockSize := int64(len(s.buf))
+	buf := make([]byte, blockSize)
+	ctr := make([]byte, blockSize)
+	for n < len(p) {
+		pos := off + int64(n)
+		x := uint64(pos / blockSize)
+		for i := range ctr {
+			ctr[i] = byte(x)
+			x >>= 8
+		}
+		s.cipher.Encrypt(buf, ctr)
+		n += copy(p[n:], buf[pos%blockSize:])
+	}
+	return n, nil
+}
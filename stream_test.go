@@ -0,0 +1,131 @@
+// stream_test.go - unit tests for stream.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"bytes"
+	"crypto/aes"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestDeterministicStreamSeek(t *testing.T) {
+	seed := []byte("stream test")
+	s1, err := NewDeterministicStream(aes.NewCipher, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := make([]byte, 1000)
+	s1.Read(all)
+
+	s2, _ := NewDeterministicStream(aes.NewCipher, seed)
+	for _, off := range []int64{0, 1, 15, 16, 17, 500, 983, 7} {
+		pos, err := s2.Seek(off, io.SeekStart)
+		if err != nil || pos != off {
+			t.Fatalf("Seek(%d) = %d, %v", off, pos, err)
+		}
+		buf := make([]byte, 17)
+		s2.Read(buf)
+		if !bytes.Equal(buf, all[off:off+17]) {
+			t.Errorf("wrong data at offset %d", off)
+		}
+	}
+
+	pos, _ := s2.Seek(-24, io.SeekCurrent)
+	if pos != 0 {
+		t.Errorf("relative seek went to %d", pos)
+	}
+	if _, err := s2.Seek(-1, io.SeekStart); err != ErrInvalidSeek {
+		t.Error("negative position accepted")
+	}
+	if _, err := s2.Seek(0, io.SeekEnd); err != ErrInvalidSeek {
+		t.Error("seek relative to end accepted")
+	}
+
+	s3, _ := NewDeterministicStream(aes.NewCipher, []byte("other"))
+	other := make([]byte, 32)
+	s3.Read(other)
+	if bytes.Equal(other, all[:32]) {
+		t.Error("different seeds gave the same stream")
+	}
+}
+
+func TestDeterministicStreamOverflow(t *testing.T) {
+	s, err := NewDeterministicStream(aes.NewCipher, []byte("overflow"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := int64(math.MaxInt64 - 3)
+	if _, err := s.Seek(start, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Read(make([]byte, 64)); n != 0 || err != ErrInvalidSeek {
+		t.Errorf("Read() past math.MaxInt64: %d, %v", n, err)
+	}
+	if n, err := s.Read(make([]byte, 3)); n != 3 || err != nil {
+		t.Errorf("Read() up to math.MaxInt64: %d, %v", n, err)
+	}
+	if _, err := s.Seek(1, io.SeekCurrent); err != ErrInvalidSeek {
+		t.Errorf("Seek() past math.MaxInt64: %v", err)
+	}
+	if pos, err := s.Seek(0, io.SeekCurrent); pos != math.MaxInt64 || err != nil {
+		t.Errorf("wrong position %d, %v", pos, err)
+	}
+}
+
+func TestDeterministicStreamReadAt(t *testing.T) {
+	s, _ := NewDeterministicStream(aes.NewCipher, []byte("read at"))
+	all := make([]byte, 100000)
+	s.Read(all)
+	pos, _ := s.Seek(0, io.SeekCurrent)
+
+	for _, test := range []struct {
+		off int64
+		n   int
+	}{
+		{0, 10}, {5, 27}, {16, 16}, {99000, 1000}, {31, 1}, {50000, 0},
+	} {
+		buf := make([]byte, test.n)
+		n, err := s.ReadAt(buf, test.off)
+		if n != test.n || err != nil {
+			t.Fatalf("ReadAt(%d, %d) = %d, %v", test.n, test.off, n, err)
+		}
+		if !bytes.Equal(buf, all[test.off:test.off+int64(test.n)]) {
+			t.Errorf("wrong data at offset %d", test.off)
+		}
+	}
+
+	if p, _ := s.Seek(0, io.SeekCurrent); p != pos {
+		t.Error("ReadAt() changed the stream position")
+	}
+	if _, err := s.ReadAt(make([]byte, 1), -1); err != ErrInvalidSeek {
+		t.Error("negative offset accepted")
+	}
+	if _, err := s.ReadAt(make([]byte, 2), math.MaxInt64-1); err != ErrInvalidSeek {
+		t.Error("ReadAt() past math.MaxInt64 did not fail")
+	}
+	if n, err := s.ReadAt(make([]byte, 1), math.MaxInt64-1); n != 1 || err != nil {
+		t.Errorf("ReadAt() at the end of the stream: %d, %v", n, err)
+	}
+}
+
+// compile-time test: DeterministicStream implements io.ReadSeeker and
+// io.ReaderAt
+var _ io.ReadSeeker = &DeterministicStream{}
+var _ io.ReaderAt = &DeterministicStream{}
@@ -0,0 +1,105 @@
+// token.go - human-readable random identifiers
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"errors"
+)
+
+// ErrAllBytesForbidden is returned by PseudoRandomDataExcluding if
+// all 256 byte values are forbidden.
+var ErrAllBytesForbidden = errors.New("all byte values are forbidden")
+
+// crockfordAlphabet is the Crockford base32 alphabet.  The letters
+// I, L, O and U are omitted to avoid confusion with 1, 1, 0 and V.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// randomString returns a string of n characters, chosen independently
+// and uniformly from the given alphabet.  The alphabet must contain
+// between 1 and 256 characters, and n must not be negative.  Bytes
+// which would introduce a bias towards the start of the alphabet are
+// rejected and redrawn.
+func (gen *Generator) randomString(alphabet string, n int) string {
+	k := len(alphabet)
+	if k < 1 || k > 256 {
+		panic("invalid alphabet size")
+	}
+	if n < 0 {
+		panic("negative string length")
+	}
+	limit := 256 - 256%k
+
+	res := make([]byte, 0, n)
+	for len(res) < n {
+		buf := gen.PseudoRandomData(uint(n - len(res)))
+		for _, b := range buf {
+			if int(b) < limit {
+				res = append(res, alphabet[int(b)%k])
+			}
+		}
+		wipe(buf)
+	}
+	return string(res)
+}
+
+// RandomToken returns a random string of n characters from the
+// Crockford base32 alphabet "0123456789ABCDEFGHJKMNPQRSTVWXYZ".  The
+// characters are chosen independently and uniformly, and the
+// alphabet avoids the easily confused letters I, L, O and U.  Each
+// character carries 5 bits of randomness.
+//
+// Tokens are intended as short, human-readable identifiers, for
+// example for codes which users need to type in.  While the output
+// comes from a cryptographically strong generator, tokens short
+// enough to be typed comfortably are usually too short to be used as
+// secrets.  RandomToken panics if n < 0.
+func (gen *Generator) RandomToken(n int) string {
+	return gen.randomString(crockfordAlphabet, n)
+}
+
+// PseudoRandomDataExcluding returns n pseudo-random bytes, none of
+// which occurs in forbidden.  This is useful for encodings where some
+// byte values, for example newline or zero bytes, cannot be used.
+// Forbidden bytes are discarded and replaced by new random bytes, so
+// that the result is uniformly distributed over the allowed values.
+// If forbidden contains all 256 byte values, ErrAllBytesForbidden is
+// returned.
+func (gen *Generator) PseudoRandomDataExcluding(n uint, forbidden []byte) ([]byte, error) {
+	var excluded [256]bool
+	numExcluded := 0
+	for _, b := range forbidden {
+		if !excluded[b] {
+			excluded[b] = true
+			numExcluded++
+		}
+	}
+	if numExcluded == 256 {
+		return nil, ErrAllBytesForbidden
+	}
+
+	res := make([]byte, 0, n)
+	for uint(len(res)) < n {
+		buf := gen.PseudoRandomData(n - uint(len(res)))
+		for _, b := range buf {
+			if !excluded[b] {
+				res = append(res, b)
+			}
+		}
+		wipe(buf)
+	}
+	return res, nil
+}
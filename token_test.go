@@ -0,0 +1,104 @@
+// token_test.go - unit tests for token.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"crypto/aes"
+	"strings"
+	"testing"
+)
+
+func TestRandomToken(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	for _, n := range []int{0, 1, 5, 16, 100} {
+		token := gen.RandomToken(n)
+		if len(token) != n {
+			t.Errorf("wrong token length %d, expected %d", len(token), n)
+		}
+		for _, c := range token {
+			if !strings.ContainsRune(crockfordAlphabet, c) {
+				t.Errorf("invalid character %q in token %q", c, token)
+			}
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != "negative string length" {
+			t.Errorf("RandomToken(-1): wrong panic %v", r)
+		}
+	}()
+	gen.RandomToken(-1)
+}
+
+func TestRandomString(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+
+	// An alphabet of size 3 requires rejection of some bytes.
+	counts := map[rune]int{}
+	n := 30000
+	for _, c := range gen.randomString("abc", n) {
+		counts[c]++
+	}
+	if len(counts) != 3 {
+		t.Fatalf("unexpected characters: %v", counts)
+	}
+	for c, k := range counts {
+		if k < n/3-500 || k > n/3+500 {
+			t.Errorf("character %q occurs %d times out of %d", c, k, n)
+		}
+	}
+}
+
+func TestPseudoRandomDataExcluding(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.Seed(1)
+
+	forbidden := []byte{0, '\n', '\r'}
+	for _, n := range []uint{0, 1, 100, 10000} {
+		data, err := gen.PseudoRandomDataExcluding(n, forbidden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint(len(data)) != n {
+			t.Errorf("wrong length %d, expected %d", len(data), n)
+		}
+		for _, b := range data {
+			if b == 0 || b == '\n' || b == '\r' {
+				t.Fatalf("forbidden byte %d in output", b)
+			}
+		}
+	}
+
+	// Only one value allowed.
+	all := make([]byte, 0, 256)
+	for i := 0; i < 256; i++ {
+		if i != 42 {
+			all = append(all, byte(i))
+		}
+	}
+	data, err := gen.PseudoRandomDataExcluding(10, all)
+	if err != nil || string(data) != strings.Repeat("*", 10) {
+		t.Errorf("wrong result %q, %v", data, err)
+	}
+
+	all = append(all, 42)
+	_, err = gen.PseudoRandomDataExcluding(10, all)
+	if err != ErrAllBytesForbidden {
+		t.Errorf("expected ErrAllBytesForbidden, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+// tpm.go - use a Trusted Platform Module as a source of entropy
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	tpmTagNoSessions = 0x8001
+	tpmCmdGetRandom  = 0x0000017B
+
+	// tpmMaxRequest is the number of random bytes requested from the
+	// TPM in one command.  All TPM 2.0 devices support requests of
+	// this size.
+	tpmMaxRequest = 32
+)
+
+var (
+	// ErrNoTPM is returned by OpenTPM() if no TPM device is
+	// available.
+	ErrNoTPM = errors.New("no TPM device available")
+
+	errTPMResponse = errors.New("malformed TPM response")
+)
+
+// TPMSource reads random bytes from the random number generator of a
+// TPM 2.0 device.  TPMSource implements the io.Reader interface, and
+// thus can be used with NewAccumulatorWithReaders().
+type TPMSource struct {
+	dev io.ReadWriteCloser
+	buf []byte
+}
+
+// OpenTPM opens the TPM device of the system.  This is only
+// supported on Linux, where the devices /dev/tpmrm0 and /dev/tpm0 are
+// tried in this order, and requires read and write access to the
+// device.  If no TPM is found, ErrNoTPM is returned.  The returned
+// TPMSource must be closed after use.
+func OpenTPM() (*TPMSource, error) {
+	dev, err := openTPMDevice()
+	if err != nil {
+		return nil, err
+	}
+	return newTPMSource(dev), nil
+}
+
+func newTPMSource(dev io.ReadWriteCloser) *TPMSource {
+	return &TPMSource{
+		dev: dev,
+		buf: make([]byte, 4096),
+	}
+}
+
+// getRandom executes a TPM2_GetRandom command, requesting n bytes.
+// The TPM may return fewer bytes than requested.
+func (src *TPMSource) getRandom(n int) ([]byte, error) {
+	cmd := make([]byte, 12)
+	binary.BigEndian.PutUint16(cmd[0:], tpmTagNoSessions)
+	binary.BigEndian.PutUint32(cmd[2:], uint32(len(cmd)))
+	binary.BigEndian.PutUint32(cmd[6:], tpmCmdGetRandom)
+	binary.BigEndian.PutUint16(cmd[10:], uint16(n))
+	_, err := src.dev.Write(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := src.dev.Read(src.buf)
+	if err != nil {
+		return nil, err
+	}
+	resp := src.buf[:k]
+	if len(resp) < 10 || int(binary.BigEndian.Uint32(resp[2:])) != len(resp) {
+		return nil, errTPMResponse
+	}
+	if rc := binary.BigEndian.Uint32(resp[6:]); rc != 0 {
+		return nil, fmt.Errorf("TPM error 0x%x", rc)
+	}
+	if len(resp) < 12 {
+		return nil, errTPMResponse
+	}
+	size := int(binary.BigEndian.Uint16(resp[10:]))
+	if size > n || 12+size != len(resp) {
+		return nil, errTPMResponse
+	}
+	return resp[12:], nil
+}
+
+// Read fills p with random bytes obtained from the TPM.
+func (src *TPMSource) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		k := len(p) - n
+		if k > tpmMaxRequest {
+			k = tpmMaxRequest
+		}
+		data, err := src.getRandom(k)
+		if err != nil {
+			return n, err
+		}
+		if len(data) == 0 {
+			return n, errTPMResponse
+		}
+		n += copy(p[n:], data)
+		wipe(data)
+	}
+	return n, nil
+}
+
+// Close closes the TPM device.
+func (src *TPMSource) Close() error {
+	return src.dev.Close()
+}
+
+// AddTPMSource starts a background goroutine which, once every
+// interval, reads 32 bytes from the system's TPM and submits these to
+// the Accumulator's entropy pools.  The goroutine stops when the
+// Accumulator is closed or when reading from the TPM fails.
+//
+// If no TPM is available (see OpenTPM()), nothing is done and false
+// is returned.  Thus, programs can call this method unconditionally
+// and make use of a TPM only where one is present.
+func (acc *Accumulator) AddTPMSource(interval time.Duration) bool {
+	src, err := OpenTPM()
+	if err != nil {
+		return false
+	}
+	acc.addTPMSource(src, interval)
+	return true
+}
+
+func (acc *Accumulator) addTPMSource(src *TPMSource, interval time.Duration) {
+	source := acc.allocateSource()
+
+	acc.sources.Add(1)
+	go func() {
+		defer acc.sources.Done()
+		defer src.Close()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		buf := make([]byte, tpmMaxRequest)
+		for seq := uint(0); ; seq++ {
+			_, err := io.ReadFull(src, buf)
+			if err != nil {
+				return
+			}
+			acc.addRandomEvent(source, seq, buf)
+			wipe(buf)
+
+			select {
+			case <-ticker.C:
+			case <-acc.stopSources:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,119 @@
+// tpm_test.go - unit tests for tpm.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// mockTPM implements just enough of the TPM 2.0 command interface to
+// answer TPM2_GetRandom commands.  The "random" bytes returned are
+// 1, 2, 3, ...; at most 10 bytes are returned per command.  If fail
+// is set, all commands fail with an error code.
+type mockTPM struct {
+	next     byte
+	response []byte
+	fail     bool
+	closed   bool
+}
+
+func (tpm *mockTPM) Write(cmd []byte) (int, error) {
+	if tpm.fail || len(cmd) != 12 ||
+		binary.BigEndian.Uint32(cmd[6:]) != tpmCmdGetRandom {
+		tpm.response = []byte{0x80, 0x01, 0, 0, 0, 10, 0, 0, 1, 0}
+		return len(cmd), nil
+	}
+	n := int(binary.BigEndian.Uint16(cmd[10:]))
+	if n > 10 {
+		n = 10
+	}
+	resp := make([]byte, 12+n)
+	binary.BigEndian.PutUint16(resp[0:], tpmTagNoSessions)
+	binary.BigEndian.PutUint32(resp[2:], uint32(len(resp)))
+	binary.BigEndian.PutUint16(resp[10:], uint16(n))
+	for i := 0; i < n; i++ {
+		tpm.next++
+		resp[12+i] = tpm.next
+	}
+	tpm.response = resp
+	return len(cmd), nil
+}
+
+func (tpm *mockTPM) Read(p []byte) (int, error) {
+	n := copy(p, tpm.response)
+	tpm.response = nil
+	return n, nil
+}
+
+func (tpm *mockTPM) Close() error {
+	tpm.closed = true
+	return nil
+}
+
+func TestTPMAbsent(t *testing.T) {
+	dev, err := openTPMDevice()
+	if err == nil {
+		dev.Close()
+		t.Skip("TPM present")
+	}
+
+	acc, _ := NewRNG("")
+	defer acc.Close()
+	if acc.AddTPMSource(time.Second) {
+		t.Error("missing TPM not detected")
+	}
+	if acc.nextSource != 0 {
+		t.Error("source allocated for missing TPM")
+	}
+}
+
+func TestTPMSource(t *testing.T) {
+	src := newTPMSource(&mockTPM{})
+	buf := make([]byte, 25)
+	n, err := src.Read(buf)
+	if n != 25 || err != nil {
+		t.Fatalf("read failed: %d %v", n, err)
+	}
+	for i, x := range buf {
+		if int(x) != i+1 {
+			t.Fatalf("wrong data %v", buf)
+		}
+	}
+
+	tpm := &mockTPM{}
+	acc, _ := NewRNG("")
+	acc.addTPMSource(newTPMSource(tpm), time.Hour)
+	time.Sleep(50 * time.Millisecond)
+	acc.poolMutex.Lock()
+	size := acc.poolSize[0]
+	acc.poolMutex.Unlock()
+	if size != 2+tpmMaxRequest {
+		t.Errorf("wrong pool size %d", size)
+	}
+	acc.Close()
+	if !tpm.closed {
+		t.Error("TPM not closed")
+	}
+
+	src = newTPMSource(&mockTPM{fail: true})
+	n, err = src.Read(buf)
+	if n != 0 || err == nil {
+		t.Error("TPM error not detected")
+	}
+}
@@ -0,0 +1,21 @@
+// +build linux
+
+package fortuna
+
+import (
+	"io"
+	"os"
+)
+
+// openTPMDevice opens the TPM character device.  The in-kernel
+// resource manager /dev/tpmrm0 is preferred, since it allows several
+// programs to use the TPM at the same time.
+func openTPMDevice() (io.ReadWriteCloser, error) {
+	for _, name := range []string{"/dev/tpmrm0", "/dev/tpm0"} {
+		dev, err := os.OpenFile(name, os.O_RDWR, 0)
+		if err == nil {
+			return dev, nil
+		}
+	}
+	return nil, ErrNoTPM
+}
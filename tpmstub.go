@@ -0,0 +1,15 @@
+// +build !linux
+
+package fortuna
+
+import (
+	"io"
+)
+
+// openTPMDevice is a dummy function which always returns ErrNoTPM on
+// this system.
+//
+// On Linux, openTPMDevice() opens the TPM character device.
+func openTPMDevice() (io.ReadWriteCloser, error) {
+	return nil, ErrNoTPM
+}
@@ -0,0 +1,110 @@
+// trace.go - optional tracing of generator activity
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"context"
+	"time"
+)
+
+// traceMinBytes is the minimal request size for which a span is
+// created.  Smaller requests are too frequent and too cheap to be
+// worth tracing.
+const traceMinBytes = 4096
+
+// Tracer is the interface used by an Accumulator to report its
+// activity to a tracing system.  The interface is deliberately
+// small, so that it can be implemented on top of OpenTelemetry or
+// similar libraries without this package depending on them.  For
+// example, an adapter for OpenTelemetry could look as follows:
+//
+//     type otelTracer struct{ trace.Tracer }
+//     type otelSpan struct{ trace.Span }
+//
+//     func (t otelTracer) Start(ctx context.Context, name string) (context.Context, fortuna.Span) {
+//         ctx, span := t.Tracer.Start(ctx, name)
+//         return ctx, otelSpan{span}
+//     }
+//     func (s otelSpan) SetAttribute(key string, value int64) {
+//         s.Span.SetAttributes(attribute.Int64(key, value))
+//     }
+//     func (s otelSpan) End() { s.Span.End() }
+//
+// Spans are children of the span found in the context passed to
+// Accumulator.RandomDataContext() or Accumulator.ReadContext().
+// Methods without a context argument, as well as background work
+// like updating the seed file, use context.Background(), so that
+// their spans have no parent.
+type Tracer interface {
+	// Start begins a new span with the given name, as a child of
+	// the span in ctx, if any.  The returned context contains the
+	// new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span represents one traced operation, see Tracer.
+type Span interface {
+	// SetAttribute attaches a numeric attribute to the span.
+	SetAttribute(key string, value int64)
+
+	// End marks the end of the operation.
+	End()
+}
+
+// timedSpan wraps a Span and records the duration of the operation
+// as an attribute when the span ends.
+type timedSpan struct {
+	Span
+	clock func() time.Time
+	start time.Time
+}
+
+func (span *timedSpan) end() {
+	d := span.clock().Sub(span.start)
+	span.SetAttribute("fortuna.duration_ns", int64(d))
+	span.End()
+}
+
+// startSpan starts a span using the tracer set by .SetTracer(), as a
+// child of the span in ctx.  If no tracer is set, nil is returned.
+// The caller must hold acc.genMutex.
+func (acc *Accumulator) startSpan(ctx context.Context, name string) *timedSpan {
+	if acc.tracer == nil {
+		return nil
+	}
+	_, span := acc.tracer.Start(ctx, name)
+	return &timedSpan{
+		Span:  span,
+		clock: acc.clock,
+		start: acc.clock(),
+	}
+}
+
+// SetTracer makes the Accumulator report its activity to the given
+// tracer.  Two kinds of spans are created: "fortuna.reseed" for every
+// reseed of the generator from the entropy pools, with the attribute
+// "fortuna.seed_bytes", and "fortuna.generate" for every request of
+// 4096 bytes or more, with the attributes "fortuna.bytes" and
+// "fortuna.rekeys".  Both kinds of span carry the attribute
+// "fortuna.duration_ns".  No random data or key material is ever
+// passed to the tracer.  If tracer is nil, which is the default,
+// tracing is disabled.
+func (acc *Accumulator) SetTracer(tracer Tracer) {
+	acc.genMutex.Lock()
+	defer acc.genMutex.Unlock()
+	acc.tracer = tracer
+}
@@ -0,0 +1,125 @@
+// trace_test.go - unit tests for trace.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type mockSpan struct {
+	name   string
+	parent *mockSpan
+	attrs  map[string]int64
+	ended  bool
+}
+
+func (span *mockSpan) SetAttribute(key string, value int64) {
+	span.attrs[key] = value
+}
+
+func (span *mockSpan) End() {
+	span.ended = true
+}
+
+type mockTracer struct {
+	spans []*mockSpan
+}
+
+type spanKey struct{}
+
+func (tracer *mockTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(*mockSpan)
+	span := &mockSpan{name: name, parent: parent, attrs: map[string]int64{}}
+	tracer.spans = append(tracer.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func TestTracer(t *testing.T) {
+	acc, _ := NewRNG("")
+	defer acc.Close()
+	now := time.Now()
+	acc.clock = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+	tracer := &mockTracer{}
+	acc.SetTracer(tracer)
+
+	acc.RandomData(100)
+	if len(tracer.spans) != 0 {
+		t.Fatal("span created for small request")
+	}
+
+	acc.addRandomEvent(0, 0, make([]byte, minPoolSize))
+	n := uint(2*maxBlocks*16 + 1)
+	acc.RandomData(n)
+	if len(tracer.spans) != 2 {
+		t.Fatalf("wrong number of spans: %d", len(tracer.spans))
+	}
+
+	reseed, gen := tracer.spans[0], tracer.spans[1]
+	if reseed.name != "fortuna.reseed" || !reseed.ended {
+		t.Errorf("wrong reseed span %v", reseed)
+	}
+	if reseed.attrs["fortuna.seed_bytes"] != 32 {
+		t.Errorf("wrong reseed attributes %v", reseed.attrs)
+	}
+	if gen.name != "fortuna.generate" || !gen.ended {
+		t.Errorf("wrong generate span %v", gen)
+	}
+	expected := map[string]int64{
+		"fortuna.bytes":       int64(n),
+		"fortuna.rekeys":      3,
+		"fortuna.duration_ns": int64(time.Millisecond),
+	}
+	for key, value := range expected {
+		if gen.attrs[key] != value {
+			t.Errorf("%s = %d, expected %d", key, gen.attrs[key], value)
+		}
+	}
+
+	for _, span := range tracer.spans {
+		if span.parent != nil {
+			t.Errorf("span %s has a parent without a context", span.name)
+		}
+	}
+
+	// Spans from the context variants are children of the caller's
+	// span.
+	ctx, request := tracer.Start(context.Background(), "request")
+	acc.addRandomEvent(0, 0, make([]byte, minPoolSize))
+	now = now.Add(time.Second)
+	buf := make([]byte, traceMinBytes)
+	acc.ReadContext(ctx, buf)
+	acc.RandomDataContext(ctx, traceMinBytes)
+	if len(tracer.spans) != 6 {
+		t.Fatalf("wrong number of spans: %d", len(tracer.spans))
+	}
+	for _, span := range tracer.spans[3:] {
+		if span.parent != request {
+			t.Errorf("span %s is not a child of the request", span.name)
+		}
+	}
+
+	acc.SetTracer(nil)
+	acc.RandomData(n)
+	if len(tracer.spans) != 6 {
+		t.Error("span created after tracing was disabled")
+	}
+}
@@ -0,0 +1,129 @@
+// unique.go - random values which are not repeated
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+const (
+	// defaultSaltWindow gives the number of recently issued salts
+	// which are remembered by .UniqueSalt().
+	defaultSaltWindow = 1024
+
+	// minUniqueSize gives the minimal length, in bytes, of values
+	// which are checked for uniqueness.  For shorter values,
+	// collisions are too likely for redrawing to be sensible.
+	minUniqueSize = 8
+)
+
+// recentSet remembers the most recently added values, up to a fixed
+// number of values.  Older values are forgotten.
+type recentSet struct {
+	size  int
+	items map[string]bool
+	order []string
+	next  int
+}
+
+func newRecentSet(size int) *recentSet {
+	if size < 1 {
+		size = 1
+	}
+	return &recentSet{
+		size:  size,
+		items: make(map[string]bool),
+	}
+}
+
+func (s *recentSet) contains(x []byte) bool {
+	return s.items[string(x)]
+}
+
+func (s *recentSet) add(x []byte) {
+	key := string(x)
+	if len(s.order) < s.size {
+		s.order = append(s.order, key)
+	} else {
+		delete(s.items, s.order[s.next])
+		s.order[s.next] = key
+		s.next = (s.next + 1) % s.size
+	}
+	s.items[key] = true
+}
+
+// uniqueData returns n random bytes which are not contained in seen,
+// and records the result in seen.
+func (gen *Generator) uniqueData(seen *recentSet, n int) []byte {
+	if n < minUniqueSize {
+		panic("random value too short to be unique")
+	}
+	for {
+		data := gen.PseudoRandomData(uint(n))
+		if !seen.contains(data) {
+			seen.add(data)
+			return data
+		}
+	}
+}
+
+// UniqueSalt returns a random salt of the given length, which must be
+// at least 8 bytes.  The generator remembers recently issued salts
+// and, in the astronomically unlikely case that a new salt coincides
+// with one of these, a new salt is drawn.  This provides defence in
+// depth for protocols which require salts to be unique.  By default
+// the last 1024 salts are remembered; the size of this window can be
+// changed using the .SetSaltWindow() method.
+func (gen *Generator) UniqueSalt(length int) []byte {
+	if gen.salts == nil {
+		gen.salts = newRecentSet(defaultSaltWindow)
+	}
+	return gen.uniqueData(gen.salts, length)
+}
+
+// SetSaltWindow sets the number of recently issued salts which are
+// remembered by .UniqueSalt().  Previously remembered salts are
+// discarded.
+func (gen *Generator) SetSaltWindow(n int) {
+	gen.salts = newRecentSet(n)
+}
+
+// Nonce returns size random bytes, intended for use as a nonce or an
+// initialisation vector for a cipher.  Random nonces are safe as long
+// as the number of nonces used with one key stays well below the
+// birthday bound of 2^(4*size) nonces.  For example, for the 12-byte
+// nonces used by AES-GCM, NIST recommends to use at most 2^32 random
+// nonces per key.
+//
+// If tracking has been enabled using .SetNonceTracking(), the
+// generator remembers recently issued nonces and never returns the
+// same nonce twice within this window.  Tracking requires nonces of
+// at least 8 bytes.
+func (gen *Generator) Nonce(size int) []byte {
+	if gen.nonces == nil {
+		return gen.PseudoRandomData(uint(size))
+	}
+	return gen.uniqueData(gen.nonces, size)
+}
+
+// SetNonceTracking sets the number of recently issued nonces which are
+// remembered by .Nonce().  If window is zero, which is the default,
+// nonces are not tracked.  Previously remembered nonces are
+// discarded.
+func (gen *Generator) SetNonceTracking(window int) {
+	if window <= 0 {
+		gen.nonces = nil
+		return
+	}
+	gen.nonces = newRecentSet(window)
+}
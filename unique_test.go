@@ -0,0 +1,98 @@
+// unique_test.go - unit tests for unique.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+)
+
+func TestRecentSet(t *testing.T) {
+	s := newRecentSet(2)
+	s.add([]byte{1})
+	s.add([]byte{2})
+	s.add([]byte{3})
+	if s.contains([]byte{1}) {
+		t.Error("old value not forgotten")
+	}
+	if !s.contains([]byte{2}) || !s.contains([]byte{3}) {
+		t.Error("recent value forgotten")
+	}
+	if len(s.items) != 2 {
+		t.Errorf("wrong set size %d", len(s.items))
+	}
+}
+
+func TestUniqueSalt(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	gen.SetSaltWindow(2)
+
+	// Reseeding the generator with the same seed forces a collision.
+	gen.Seed(1)
+	salt1 := gen.UniqueSalt(16)
+	gen.Seed(1)
+	salt2 := gen.UniqueSalt(16)
+	if len(salt1) != 16 || len(salt2) != 16 {
+		t.Fatal("wrong salt length")
+	}
+	if bytes.Compare(salt1, salt2) == 0 {
+		t.Error("repeated salt not detected")
+	}
+	gen.Seed(1)
+	first := gen.PseudoRandomData(16)
+	second := gen.PseudoRandomData(16)
+	if bytes.Compare(salt1, first) != 0 || bytes.Compare(salt2, second) != 0 {
+		t.Error("salt not redrawn")
+	}
+
+	// Once the salt has left the window, it is not detected any more.
+	gen.UniqueSalt(16)
+	gen.UniqueSalt(16)
+	gen.Seed(1)
+	salt3 := gen.UniqueSalt(16)
+	if bytes.Compare(salt1, salt3) != 0 {
+		t.Error("salt window too large")
+	}
+}
+
+func TestNonce(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	for _, size := range []int{1, 12, 24} {
+		if n := len(gen.Nonce(size)); n != size {
+			t.Errorf("wrong nonce length %d, expected %d", n, size)
+		}
+	}
+
+	// Without tracking, repeated nonces are not detected.
+	gen.Seed(1)
+	nonce1 := gen.Nonce(12)
+	gen.Seed(1)
+	nonce2 := gen.Nonce(12)
+	if bytes.Compare(nonce1, nonce2) != 0 {
+		t.Error("untracked nonce redrawn")
+	}
+
+	gen.SetNonceTracking(10)
+	gen.Seed(1)
+	nonce1 = gen.Nonce(12)
+	gen.Seed(1)
+	nonce2 = gen.Nonce(12)
+	if bytes.Compare(nonce1, nonce2) == 0 {
+		t.Error("repeated nonce not detected")
+	}
+}
@@ -0,0 +1,55 @@
+// uuid_test.go - unit tests for uuid.go
+// Copyright (C) 2013  Jochen Voss <voss@seehuhn.de>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fortuna
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+	"time"
+)
+
+func TestUUIDv7(t *testing.T) {
+	gen := NewGenerator(aes.NewCipher)
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	gen.clock = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+
+	var prev [16]byte
+	for i := 0; i < 1000; i++ {
+		id := gen.UUIDv7()
+		if id[6]>>4 != 7 {
+			t.Fatalf("wrong version in %x", id)
+		}
+		if id[8]>>6 != 2 {
+			t.Fatalf("wrong variant in %x", id)
+		}
+		ms := uint64(0)
+		for _, b := range id[:6] {
+			ms = ms<<8 | uint64(b)
+		}
+		if int64(ms) != now.UnixNano()/int64(time.Millisecond) {
+			t.Fatalf("wrong timestamp in %x", id)
+		}
+		if i > 0 && bytes.Compare(prev[:], id[:]) >= 0 {
+			t.Fatalf("UUIDs not ordered: %x >= %x", prev, id)
+		}
+		prev = id
+	}
+}